@@ -0,0 +1,263 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// psiMemory is the memory pressure stall information file.
+// See https://www.kernel.org/doc/html/latest/accounting/psi.html
+var psiMemory = "/proc/pressure/memory"
+
+// adaptiveLevel is a step of the adaptive mode. We move to mode when
+// the available memory drops below availableBelow percent of the total
+// memory, or when the memory PSI rises above psiAbove percent.
+type adaptiveLevel struct {
+	mode           ksmMode
+	availableBelow float64
+	psiAbove       float64
+}
+
+// ksmAdaptiveLevels are the adaptive mode steps, from the least to the
+// most aggressive one. Below the first step, the initial KSM settings
+// are restored.
+var ksmAdaptiveLevels = []adaptiveLevel{
+	{ksmSlow, 50, 1},
+	{ksmStandard, 25, 5},
+	{ksmAggressive, 10, 10},
+}
+
+// ksmAdaptiveInterval is how often we sample the memory pressure.
+var ksmAdaptiveInterval = 5 * time.Second
+
+// ksmAdaptiveHold is how long we stay on a step before moving down to
+// the previous one, once the memory pressure clears.
+var ksmAdaptiveHold = 60 * time.Second
+
+// memoryPressure is a memory pressure sample.
+type memoryPressure struct {
+	// availablePercent is the available memory, in percent of the
+	// total memory.
+	availablePercent float64
+
+	// psiSome is the share of time, in percent, some tasks were
+	// stalled on memory over the last 10 seconds. It is negative
+	// when PSI is not available.
+	psiSome float64
+}
+
+// readPSISome returns the memory PSI "some" 10 seconds average.
+func readPSISome() (float64, error) {
+	f, err := os.Open(psiMemory)
+	if err != nil {
+		return -1, err
+	}
+	defer f.Close()
+
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		// e.g. "some avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+		fields := strings.Fields(scan.Text())
+		if len(fields) < 2 || fields[0] != "some" {
+			continue
+		}
+
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "avg10=") {
+				continue
+			}
+
+			return strconv.ParseFloat(strings.TrimPrefix(field, "avg10="), 64)
+		}
+	}
+
+	return -1, fmt.Errorf("Could not find memory PSI some average in %s", psiMemory)
+}
+
+func readMemoryPressure() (memoryPressure, error) {
+	var p memoryPressure
+
+	values, err := readMemInfo()
+	if err != nil {
+		return p, err
+	}
+
+	total, ok := values["MemTotal"]
+	if !ok || total == 0 {
+		return p, fmt.Errorf("Could not find total memory")
+	}
+
+	available, ok := values["MemAvailable"]
+	if !ok {
+		return p, fmt.Errorf("Could not find available memory")
+	}
+
+	p.availablePercent = float64(available) * 100 / float64(total)
+
+	// PSI is only available from Linux 4.20
+	p.psiSome, err = readPSISome()
+	if err != nil {
+		throttlerLog.WithError(err).Debug("memory PSI not available")
+		p.psiSome = -1
+	}
+
+	return p, nil
+}
+
+// level returns the adaptive step we should be on for this memory
+// pressure, 0 meaning the initial KSM settings.
+func (p memoryPressure) level() int {
+	level := 0
+
+	for i, l := range ksmAdaptiveLevels {
+		if p.availablePercent < l.availableBelow || (p.psiSome >= 0 && p.psiSome > l.psiAbove) {
+			level = i + 1
+		}
+	}
+
+	return level
+}
+
+// kickLevel returns the adaptive step matching a kick mode, or the
+// most aggressive step if the kick mode is not one of them.
+func kickLevel(mode ksmMode) int {
+	for i, l := range ksmAdaptiveLevels {
+		if l.mode == mode {
+			return i + 1
+		}
+	}
+
+	return len(ksmAdaptiveLevels)
+}
+
+// levelMode returns the KSM mode of an adaptive step.
+func levelMode(level int) ksmMode {
+	if level == 0 {
+		return ksmInitial
+	}
+
+	return ksmAdaptiveLevels[level-1].mode
+}
+
+type adaptiveState struct {
+	// level is the current adaptive step.
+	level int
+
+	// changed is when we moved to the current step.
+	changed time.Time
+
+	// kickLevel is the step kicks keep us on, until kickDeadline.
+	kickLevel    int
+	kickDeadline time.Time
+}
+
+// kick keeps us at least on the kick mode step for the kick hold
+// duration. A kick never shortens nor lowers a pending one.
+func (a *adaptiveState) kick(k *ksm, kick ksmKick) {
+	now := time.Now()
+
+	if now.After(a.kickDeadline) {
+		a.kickLevel = 0
+	}
+
+	if l := kickLevel(kick.mode); l > a.kickLevel {
+		a.kickLevel = l
+	}
+
+	if deadline := now.Add(kick.hold); deadline.After(a.kickDeadline) {
+		a.kickDeadline = deadline
+	}
+
+	k.Lock()
+	k.throttleDeadline = a.kickDeadline
+	k.Unlock()
+}
+
+// step samples the memory pressure and moves KSM to the matching
+// adaptive step. We move up right away, but only move down one step
+// at a time, after holding the current one for ksmAdaptiveHold.
+func (a *adaptiveState) step(k *ksm) {
+	now := time.Now()
+
+	target := a.level
+	p, err := readMemoryPressure()
+	if err != nil {
+		throttlerLog.WithError(err).Error("could not read memory pressure")
+	} else {
+		target = p.level()
+	}
+
+	// A kick keeps us at least on the kick mode step for a while.
+	if !a.kickDeadline.IsZero() {
+		if now.Before(a.kickDeadline) {
+			if a.kickLevel > target {
+				target = a.kickLevel
+			}
+		} else {
+			a.kickLevel = 0
+			a.kickDeadline = time.Time{}
+
+			k.Lock()
+			k.throttleDeadline = time.Time{}
+			k.Unlock()
+		}
+	}
+
+	switch {
+	case target > a.level:
+	case target < a.level && now.Sub(a.changed) >= ksmAdaptiveHold:
+		target = a.level - 1
+	default:
+		return
+	}
+
+	if err := k.moveTo(levelMode(target)); err != nil {
+		throttlerLog.WithError(err).WithFields(logrus.Fields{
+			"available-percent": p.availablePercent,
+			"psi-some":          p.psiSome,
+			"level":             target,
+		}).Error("adaptive mode failed to tune")
+		return
+	}
+
+	a.level = target
+	a.changed = now
+}
+
+// adaptiveLoop follows the memory pressure and kicks, until stop is closed.
+func (k *ksm) adaptiveLoop(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(ksmAdaptiveInterval)
+	defer ticker.Stop()
+
+	var a adaptiveState
+
+	for {
+		a.step(k)
+
+		select {
+		case <-stop:
+			return
+
+		case <-k.kickChannel:
+			if kick, ok := k.takeKick(); ok {
+				a.kick(k, kick)
+			}
+
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,184 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const psiContent = `some avg10=2.50 avg60=1.00 avg300=0.50 total=123456
+full avg10=0.50 avg60=0.10 avg300=0.00 total=6789
+`
+
+// setTestMemInfo points memInfo to a file with the given total and
+// available memory, in kB.
+func setTestMemInfo(t *testing.T, total, available int64) {
+	err := ioutil.WriteFile(memInfo, []byte(fmt.Sprintf(
+		"MemTotal:       %d kB\nMemAvailable:   %d kB\nAnonPages: %v kB\n",
+		total, available, anonPagesMemory)), 0644)
+	assert.Nil(t, err)
+}
+
+func resetTestMemInfo(t *testing.T) {
+	err := ioutil.WriteFile(memInfo, []byte(fmt.Sprintf("AnonPages: %v kB", anonPagesMemory)), 0644)
+	assert.Nil(t, err)
+}
+
+func setTestPSI(t *testing.T, content string) func() {
+	f, err := ioutil.TempFile("", "ksmthrottler-psi")
+	assert.Nil(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString(content)
+	assert.Nil(t, err)
+
+	saved := psiMemory
+	psiMemory = f.Name()
+
+	return func() {
+		psiMemory = saved
+		os.Remove(f.Name())
+	}
+}
+
+func waitForKnob(k *ksm, mode ksmMode, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		k.Lock()
+		knob := k.currentKnob
+		k.Unlock()
+
+		if knob == mode {
+			return true
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return false
+}
+
+func TestReadPSISome(t *testing.T) {
+	cleanup := setTestPSI(t, psiContent)
+	defer cleanup()
+
+	psi, err := readPSISome()
+	assert.Nil(t, err)
+	assert.Equal(t, 2.5, psi)
+
+	psiMemory = "/does/not/exist"
+	_, err = readPSISome()
+	assert.NotNil(t, err)
+}
+
+func TestReadMemoryPressure(t *testing.T) {
+	defer resetTestMemInfo(t)
+
+	cleanup := setTestPSI(t, psiContent)
+	defer cleanup()
+
+	setTestMemInfo(t, 1000, 250)
+
+	p, err := readMemoryPressure()
+	assert.Nil(t, err)
+	assert.Equal(t, 25.0, p.availablePercent)
+	assert.Equal(t, 2.5, p.psiSome)
+
+	// No PSI
+	psiMemory = "/does/not/exist"
+	p, err = readMemoryPressure()
+	assert.Nil(t, err)
+	assert.True(t, p.psiSome < 0)
+
+	resetTestMemInfo(t)
+	_, err = readMemoryPressure()
+	assert.NotNil(t, err)
+}
+
+func TestMemoryPressureLevel(t *testing.T) {
+	data := []struct {
+		p     memoryPressure
+		level int
+	}{
+		{memoryPressure{90, 0}, 0},
+		{memoryPressure{90, -1}, 0},
+		{memoryPressure{40, -1}, 1},
+		{memoryPressure{90, 2}, 1},
+		{memoryPressure{20, 0}, 2},
+		{memoryPressure{90, 6}, 2},
+		{memoryPressure{5, 0}, 3},
+		{memoryPressure{60, 20}, 3},
+	}
+
+	for _, d := range data {
+		assert.Equal(t, d.level, d.p.level(), "%+v", d.p)
+	}
+}
+
+func TestKSMAdaptiveMode(t *testing.T) {
+	defer resetTestMemInfo(t)
+
+	cleanup := setTestPSI(t, "")
+	defer cleanup()
+
+	savedInterval := ksmAdaptiveInterval
+	savedHold := ksmAdaptiveHold
+	defer func() {
+		ksmAdaptiveInterval = savedInterval
+		ksmAdaptiveHold = savedHold
+	}()
+
+	ksmAdaptiveInterval = 20 * time.Millisecond
+	ksmAdaptiveHold = 200 * time.Millisecond
+	ksmAggressiveInterval = 300 * time.Millisecond
+
+	setTestMemInfo(t, 1000, 900)
+
+	k, err := startKSM(defaultKSMRoot, ksmAdaptive)
+	assert.Nil(t, err)
+	defer k.restore()
+
+	s, err := k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmAdaptive, s.policy)
+	assert.Equal(t, ksmInitial, s.knob)
+	assert.True(t, s.throttling)
+
+	// Running out of memory: we jump to the most aggressive step.
+	setTestMemInfo(t, 1000, 50)
+	assert.True(t, waitForKnob(k, ksmAggressive, time.Second))
+
+	// Pressure clears: we step down one step at a time.
+	setTestMemInfo(t, 1000, 900)
+	assert.True(t, waitForKnob(k, ksmStandard, time.Second))
+	assert.True(t, waitForKnob(k, ksmSlow, time.Second))
+	assert.True(t, waitForKnob(k, ksmInitial, time.Second))
+
+	// Kicks still work.
+	k.kick()
+	assert.True(t, waitForKnob(k, ksmAggressive, time.Second))
+
+	s, err = k.status()
+	assert.Nil(t, err)
+	assert.True(t, s.remaining > 0)
+
+	assert.True(t, waitForKnob(k, ksmInitial, 2*time.Second))
+
+	err = k.setMode(ksmOff)
+	assert.Nil(t, err)
+
+	s, err = k.status()
+	assert.Nil(t, err)
+	assert.False(t, s.throttling)
+}
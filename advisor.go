@@ -0,0 +1,56 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+)
+
+// advisorSetting describes how the kernel scan advisor should tune
+// pages_to_scan for a KSM mode.
+type advisorSetting struct {
+	// targetScanTime is the time, in seconds, ksmd should take to
+	// scan all mergeable areas.
+	targetScanTime uint32
+
+	// maxCPU is the share of one CPU, in percent, ksmd may use.
+	maxCPU uint32
+}
+
+var ksmAdvisorSettings = map[ksmMode]advisorSetting{
+	ksmSlow:       {300, 10}, // Scan everything every 5mn, using at most 10% of a CPU
+	ksmStandard:   {100, 30}, // Scan everything every 100s, using at most 30% of a CPU
+	ksmAggressive: {20, 70},  // Scan everything every 20s, using at most 70% of a CPU
+}
+
+// advisorSetting is unlocked. You should take the ksm lock before calling it.
+// It returns the setting for a mode with pages_to_scan tuned by the
+// kernel scan advisor. Without an advisor, the setting is unchanged and
+// we compute pages_to_scan ourselves.
+func (k *ksm) advisorSetting(mode ksmMode, s ksmSetting) ksmSetting {
+	if _, ok := k.knobs[ksmAdvisorMode]; !ok {
+		return s
+	}
+
+	a, ok := ksmAdvisorSettings[mode]
+	if !ok || !s.run {
+		return s
+	}
+
+	knobs := make(map[string]string)
+	for name, value := range s.knobs {
+		knobs[name] = value
+	}
+
+	knobs[ksmAdvisorMode] = ksmAdvisorScanTime
+	knobs[ksmAdvisorTargetScanTime] = fmt.Sprintf("%d", a.targetScanTime)
+	knobs[ksmAdvisorMaxCPU] = fmt.Sprintf("%d", a.maxCPU)
+
+	s.knobs = knobs
+
+	return s
+}
@@ -0,0 +1,75 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdvisorSettingFallback(t *testing.T) {
+	k := initKSM(defaultKSMRoot, t)
+	defer k.restore()
+
+	// No scan advisor, we compute pages_to_scan ourselves
+	setting := k.advisorSetting(ksmAggressive, ksmSettings[ksmAggressive])
+	assert.Equal(t, ksmSettings[ksmAggressive], setting)
+}
+
+func TestKSMAdvisorMode(t *testing.T) {
+	knobs := map[string]string{
+		ksmAdvisorMode:           "[none] scan-time",
+		ksmAdvisorMaxCPU:         "70",
+		ksmAdvisorTargetScanTime: "200",
+	}
+
+	err := writeKSMCounters(knobs)
+	defer removeKSMCounters(knobs)
+	assert.Nil(t, err)
+
+	// Let's make the throttling down faster, for quicker tests purpose.
+	ksmAggressiveInterval = 500 * time.Millisecond
+
+	k, err := startKSM(defaultKSMRoot, ksmAdvisorPolicy)
+	assert.Nil(t, err)
+	defer k.restore()
+
+	initialPagesToScan := readTestPagesToScan(t)
+
+	s, err := k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmAdvisorPolicy, s.policy)
+	assert.Equal(t, ksmInitial, s.knob)
+	assert.True(t, s.throttling)
+
+	k.kick()
+	assert.True(t, waitForKnob(k, ksmAggressive, time.Second))
+
+	// The advisor tunes pages_to_scan
+	assert.Equal(t, ksmAdvisorScanTime, readTestKnob(t, ksmAdvisorMode))
+	assert.Equal(t, "20", readTestKnob(t, ksmAdvisorTargetScanTime))
+	assert.Equal(t, "70", readTestKnob(t, ksmAdvisorMaxCPU))
+	assert.Equal(t, "1", readTestKnob(t, ksmRunFile))
+	assert.Equal(t, initialPagesToScan, readTestPagesToScan(t))
+
+	// And follows the throttling down chain
+	assert.True(t, waitForKnob(k, ksmStandard, 2*ksmAggressiveInterval))
+	assert.Equal(t, "100", readTestKnob(t, ksmAdvisorTargetScanTime))
+	assert.Equal(t, "30", readTestKnob(t, ksmAdvisorMaxCPU))
+
+	// Fixed modes don't use the advisor
+	err = k.setMode(ksmStandard)
+	assert.Nil(t, err)
+	assert.Equal(t, ksmAdvisorNone, readTestKnob(t, ksmAdvisorMode))
+	assert.Equal(t, "200", readTestKnob(t, ksmAdvisorTargetScanTime))
+
+	pagesToScan, err := ksmSettings[ksmStandard].pagesToScan(defaultKSMRoot)
+	assert.Nil(t, err)
+	assert.Equal(t, pagesToScan, readTestPagesToScan(t))
+}
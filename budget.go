@@ -0,0 +1,347 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+)
+
+// procRoot is where we look for ksmd.
+var procRoot = "/proc"
+
+// auxvPath is our auxiliary vector. Its AT_CLKTCK entry is the kernel
+// USER_HZ, the /proc/<pid>/stat time unit, that sysconf(_SC_CLK_TCK)
+// returns.
+var auxvPath = "/proc/self/auxv"
+
+const atClkTck = 17
+
+// nativeEndian is the byte order of the auxiliary vector.
+var nativeEndian binary.ByteOrder
+
+func init() {
+	i := uint16(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// ksmBudgetPercent is the share of one CPU, in percent, ksmd may use
+// in budget mode.
+var ksmBudgetPercent = 5.0
+
+// ksmBudgetInterval is how often we measure the ksmd CPU usage.
+var ksmBudgetInterval = 5 * time.Second
+
+const (
+	// We scan at least ksmBudgetMinPages pages, and then sleep at
+	// most ksmBudgetMaxSleepMS between scans.
+	ksmBudgetMinPages   int64  = 16
+	ksmBudgetMaxSleepMS uint32 = 1000
+
+	// We only speed ksmd up when it uses less than
+	// ksmBudgetHeadroom of its budget, to avoid oscillating.
+	ksmBudgetHeadroom = 0.8
+)
+
+// findKSMd returns the ksmd kernel thread PID.
+func findKSMd() (int, error) {
+	entries, err := ioutil.ReadDir(procRoot)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		comm, err := ioutil.ReadFile(filepath.Join(procRoot, entry.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+
+		if strings.TrimSpace(string(comm)) == "ksmd" {
+			return pid, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Could not find ksmd in %s", procRoot)
+}
+
+// readClockTicks returns the kernel USER_HZ, from our auxiliary vector
+// of (type, value) machine words.
+func readClockTicks() (int64, error) {
+	data, err := ioutil.ReadFile(auxvPath)
+	if err != nil {
+		return 0, err
+	}
+
+	word := int(unsafe.Sizeof(uintptr(0)))
+	value := func(b []byte) uint64 {
+		if word == 4 {
+			return uint64(nativeEndian.Uint32(b))
+		}
+		return nativeEndian.Uint64(b)
+	}
+
+	for i := 0; i+2*word <= len(data); i += 2 * word {
+		if value(data[i:]) == atClkTck {
+			if ticks := int64(value(data[i+word:])); ticks > 0 {
+				return ticks, nil
+			}
+			break
+		}
+	}
+
+	return 0, fmt.Errorf("Could not find the clock ticks rate in %s", auxvPath)
+}
+
+// readCPUTicks returns the user and system time of a process, in
+// clock ticks. See proc(5).
+func readCPUTicks(pid int) (int64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, err
+	}
+
+	// The command name may contain spaces and parentheses,
+	// the fields we care about come after it.
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+
+	// utime and stime are the 14th and 15th fields, the first
+	// one after the command name being the 3rd.
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("Invalid stat for PID %d", pid)
+	}
+
+	utime, err := strconv.ParseInt(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	stime, err := strconv.ParseInt(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return utime + stime, nil
+}
+
+type budgetState struct {
+	started bool
+
+	// pages and sleepMS are the values ksmd currently runs with.
+	pages   int64
+	sleepMS uint32
+
+	// We never scan faster than the kick mode settings.
+	maxPages   int64
+	minSleepMS uint32
+
+	// clockTicks is the kernel USER_HZ.
+	clockTicks int64
+
+	pid       int
+	lastTicks int64
+	lastTime  time.Time
+}
+
+// start runs ksmd as slow as we can, for it not to exceed its budget
+// before the first measure. update then speeds it up to the kick mode
+// settings at most.
+func (b *budgetState) start(k *ksm) error {
+	setting := ksmSettings[ksmKickMode]
+
+	clockTicks, err := readClockTicks()
+	if err != nil {
+		return err
+	}
+
+	pagesToScan, err := setting.pagesToScan(k.root)
+	if err != nil {
+		return err
+	}
+
+	maxPages, err := strconv.ParseInt(pagesToScan, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	if maxPages < ksmBudgetMinPages {
+		maxPages = ksmBudgetMinPages
+	}
+
+	b.clockTicks = clockTicks
+	b.maxPages = maxPages
+	b.minSleepMS = setting.scanIntervalMS
+
+	sleepMS := ksmBudgetMaxSleepMS
+	if b.minSleepMS > sleepMS {
+		sleepMS = b.minSleepMS
+	}
+
+	if err := b.tune(k, ksmBudgetMinPages, sleepMS); err != nil {
+		return err
+	}
+
+	k.Lock()
+	k.setKnob(ksmBudget)
+	k.Unlock()
+
+	b.started = true
+
+	return nil
+}
+
+func (b *budgetState) tune(k *ksm, pages int64, sleepMS uint32) error {
+	if err := k.tuneValues(fmt.Sprintf("%d", pages), fmt.Sprintf("%d", sleepMS), ksmSettings[ksmKickMode]); err != nil {
+		return err
+	}
+
+	b.pages = pages
+	b.sleepMS = sleepMS
+
+	return nil
+}
+
+// next returns the values ksmd should run with, given how much CPU it
+// used since the last measure. When over budget, we first scan less
+// pages and then sleep longer. When under budget, we first sleep less
+// and then scan more pages.
+func (b *budgetState) next(cpuPercent float64) (int64, uint32) {
+	pages, sleepMS := b.pages, b.sleepMS
+
+	switch {
+	case cpuPercent > ksmBudgetPercent:
+		if pages > ksmBudgetMinPages {
+			pages = int64(float64(pages) * ksmBudgetPercent / cpuPercent)
+			if pages < ksmBudgetMinPages {
+				pages = ksmBudgetMinPages
+			}
+		} else if sleepMS < ksmBudgetMaxSleepMS {
+			sleepMS *= 2
+			if sleepMS == 0 {
+				sleepMS = 1
+			}
+			if sleepMS > ksmBudgetMaxSleepMS {
+				sleepMS = ksmBudgetMaxSleepMS
+			}
+		}
+
+	case cpuPercent < ksmBudgetPercent*ksmBudgetHeadroom:
+		if sleepMS > b.minSleepMS {
+			sleepMS /= 2
+			if sleepMS < b.minSleepMS {
+				sleepMS = b.minSleepMS
+			}
+		} else if pages < b.maxPages {
+			pages *= 2
+			if pages > b.maxPages {
+				pages = b.maxPages
+			}
+		}
+	}
+
+	return pages, sleepMS
+}
+
+// update measures the ksmd CPU usage and tunes KSM to keep it within
+// our budget.
+func (b *budgetState) update(k *ksm, now time.Time) {
+	if !b.started {
+		if err := b.start(k); err != nil {
+			throttlerLog.WithError(err).Error("budget mode failed to tune")
+			return
+		}
+	}
+
+	if b.pid == 0 {
+		pid, err := findKSMd()
+		if err != nil {
+			throttlerLog.WithError(err).Error("could not find ksmd")
+			return
+		}
+		b.pid = pid
+		b.lastTime = time.Time{}
+	}
+
+	ticks, err := readCPUTicks(b.pid)
+	if err != nil {
+		throttlerLog.WithError(err).WithField("pid", b.pid).Error("could not read ksmd CPU time")
+		b.pid = 0
+		return
+	}
+
+	lastTicks, lastTime := b.lastTicks, b.lastTime
+	b.lastTicks, b.lastTime = ticks, now
+
+	elapsed := now.Sub(lastTime).Seconds()
+	if lastTime.IsZero() || elapsed <= 0 {
+		return
+	}
+
+	cpuPercent := float64(ticks-lastTicks) / float64(b.clockTicks) / elapsed * 100
+
+	pages, sleepMS := b.next(cpuPercent)
+	if pages == b.pages && sleepMS == b.sleepMS {
+		return
+	}
+
+	logger := throttlerLog.WithFields(logrus.Fields{
+		"cpu-percent":     cpuPercent,
+		"pages-to-scan":   pages,
+		"sleep-millisecs": sleepMS,
+	})
+
+	if err := b.tune(k, pages, sleepMS); err != nil {
+		logger.WithError(err).Error("budget mode failed to tune")
+		return
+	}
+
+	logger.Debug("ksmd CPU budget tuning")
+}
+
+// budgetLoop keeps ksmd running as fast as its CPU budget allows,
+// until stop is closed.
+func (k *ksm) budgetLoop(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(ksmBudgetInterval)
+	defer ticker.Stop()
+
+	var b budgetState
+
+	b.update(k, time.Now())
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case <-k.kickChannel:
+			// We're already scanning as fast as our budget allows
+			k.takeKick()
+
+		case now := <-ticker.C:
+			b.update(k, now)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const ksmdPid = 42
+
+// setTestProc creates a fake proc root with a ksmd thread.
+func setTestProc(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "ksmthrottler-proc")
+	assert.Nil(t, err)
+
+	for pid, comm := range map[int]string{1: "systemd", ksmdPid: "ksmd"} {
+		pidDir := filepath.Join(dir, fmt.Sprintf("%d", pid))
+
+		err = os.MkdirAll(pidDir, 0755)
+		assert.Nil(t, err)
+
+		err = ioutil.WriteFile(filepath.Join(pidDir, "comm"), []byte(comm+"\n"), 0644)
+		assert.Nil(t, err)
+	}
+
+	saved := procRoot
+	procRoot = dir
+
+	setKSMdTicks(t, 0, 0)
+
+	return func() {
+		procRoot = saved
+		os.RemoveAll(dir)
+	}
+}
+
+func setKSMdTicks(t *testing.T, utime, stime int64) {
+	stat := fmt.Sprintf("%d (ksmd) S 2 0 0 0 -1 2129984 0 0 0 0 %d %d 0 0 25 5 1 0 12 0 0 "+
+		"18446744073709551615 0 0 0 0 0 0 0 2147483647 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0\n",
+		ksmdPid, utime, stime)
+
+	err := ioutil.WriteFile(filepath.Join(procRoot, fmt.Sprintf("%d", ksmdPid), "stat"), []byte(stat), 0644)
+	assert.Nil(t, err)
+}
+
+func readTestPagesToScan(t *testing.T) string {
+	data, err := ioutil.ReadFile(filepath.Join(defaultKSMRoot, ksmPagesToScan))
+	assert.Nil(t, err)
+
+	return strings.TrimSpace(string(data))
+}
+
+func TestFindKSMd(t *testing.T) {
+	cleanup := setTestProc(t)
+	defer cleanup()
+
+	pid, err := findKSMd()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmdPid, pid)
+
+	procRoot = "/does/not/exist"
+	_, err = findKSMd()
+	assert.NotNil(t, err)
+}
+
+func TestReadCPUTicks(t *testing.T) {
+	cleanup := setTestProc(t)
+	defer cleanup()
+
+	setKSMdTicks(t, 12, 30)
+
+	ticks, err := readCPUTicks(ksmdPid)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(42), ticks)
+
+	// Command names with spaces and parentheses
+	err = ioutil.WriteFile(filepath.Join(procRoot, "1", "stat"),
+		[]byte("1 (a (b) c) S 0 1 1 0 -1 4194560 0 0 0 0 7 3 0 0 20 0 1 0 1 0 0\n"), 0644)
+	assert.Nil(t, err)
+
+	ticks, err = readCPUTicks(1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), ticks)
+
+	_, err = readCPUTicks(ksmdPid + 1)
+	assert.NotNil(t, err)
+}
+
+func TestBudgetNext(t *testing.T) {
+	b := budgetState{maxPages: 1000, minSleepMS: 10}
+
+	data := []struct {
+		pages       int64
+		sleepMS     uint32
+		cpuPercent  float64
+		nextPages   int64
+		nextSleepMS uint32
+		description string
+	}{
+		{1000, 10, 10, 500, 10, "over budget: scan less"},
+		{20, 10, 50, 16, 10, "over budget: scan the minimum"},
+		{16, 10, 10, 16, 20, "over budget: sleep longer"},
+		{16, 800, 10, 16, 1000, "over budget: sleep the maximum"},
+		{16, 1000, 10, 16, 1000, "over budget: stuck"},
+		{500, 10, 4.5, 500, 10, "within budget: hold"},
+		{500, 10, 5, 500, 10, "at budget: hold"},
+		{16, 40, 1, 16, 20, "under budget: sleep less"},
+		{16, 10, 1, 32, 10, "under budget: scan more"},
+		{800, 10, 0, 1000, 10, "under budget: scan the maximum"},
+		{1000, 10, 0, 1000, 10, "under budget: stuck"},
+	}
+
+	for _, d := range data {
+		b.pages, b.sleepMS = d.pages, d.sleepMS
+
+		pages, sleepMS := b.next(d.cpuPercent)
+		assert.Equal(t, d.nextPages, pages, d.description)
+		assert.Equal(t, d.nextSleepMS, sleepMS, d.description)
+	}
+}
+
+func TestBudgetUpdate(t *testing.T) {
+	cleanup := setTestProc(t)
+	defer cleanup()
+
+	k := initKSM(defaultKSMRoot, t)
+	defer k.restore()
+
+	maxPages, err := ksmSettings[ksmKickMode].pagesToScan(defaultKSMRoot)
+	assert.Nil(t, err)
+
+	var b budgetState
+	now := time.Now()
+
+	// We start as slow as we can
+	b.update(k, now)
+	assert.Equal(t, ksmBudget, k.currentKnob)
+	assert.Equal(t, fmt.Sprintf("%d", ksmBudgetMinPages), readTestPagesToScan(t))
+	assert.Equal(t, ksmBudgetMaxSleepMS, b.sleepMS)
+
+	// ksmd is idle: We speed it up to the kick mode
+	for i := 0; i < 50 && b.pages < b.maxPages; i++ {
+		now = now.Add(10 * time.Second)
+		b.update(k, now)
+	}
+	assert.Equal(t, b.minSleepMS, b.sleepMS)
+	assert.Equal(t, maxPages, readTestPagesToScan(t))
+
+	// 10% of a CPU
+	setKSMdTicks(t, b.clockTicks/2, b.clockTicks/2)
+	now = now.Add(10 * time.Second)
+	b.update(k, now)
+	assert.Equal(t, b.maxPages/2, b.pages)
+	assert.Equal(t, fmt.Sprintf("%d", b.maxPages/2), readTestPagesToScan(t))
+}
+
+func TestReadClockTicks(t *testing.T) {
+	ticks, err := readClockTicks()
+	assert.Nil(t, err)
+	assert.True(t, ticks > 0)
+
+	f, err := ioutil.TempFile("", "ksmthrottler-auxv")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	f.Close()
+
+	saved := auxvPath
+	auxvPath = f.Name()
+	defer func() {
+		auxvPath = saved
+	}()
+
+	writeAuxv := func(entries ...uint64) {
+		var data []byte
+		for _, e := range entries {
+			word := make([]byte, unsafe.Sizeof(uintptr(0)))
+			if len(word) == 4 {
+				nativeEndian.PutUint32(word, uint32(e))
+			} else {
+				nativeEndian.PutUint64(word, e)
+			}
+			data = append(data, word...)
+		}
+
+		err := ioutil.WriteFile(auxvPath, data, 0644)
+		assert.Nil(t, err)
+	}
+
+	// AT_PAGESZ, AT_CLKTCK and AT_NULL
+	writeAuxv(6, 4096, atClkTck, 250, 0, 0)
+	ticks, err = readClockTicks()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(250), ticks)
+
+	writeAuxv(6, 4096, 0, 0)
+	_, err = readClockTicks()
+	assert.NotNil(t, err)
+}
+
+func TestKSMBudgetMode(t *testing.T) {
+	cleanup := setTestProc(t)
+	defer cleanup()
+
+	k, err := startKSM(defaultKSMRoot, ksmBudget)
+	assert.Nil(t, err)
+	defer k.restore()
+
+	assert.True(t, waitForKnob(k, ksmBudget, time.Second))
+
+	s, err := k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmBudget, s.policy)
+	assert.True(t, s.throttling)
+
+	// Kicks don't block
+	k.kick()
+
+	err = k.setMode(ksmInitial)
+	assert.Nil(t, err)
+	assert.Equal(t, ksmInitial, k.currentKnob)
+}
@@ -0,0 +1,547 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// throttlerConfig describes the throttler configuration file.
+//
+// Each [mode.<name>] table overrides the settings of a KSM mode, and
+// the [[throttle]] array describes the throttling down chain: When
+// kicked, the throttler moves to the first step mode and then walks
+// down the chain, holding each step for its hold duration. After the
+// last step, the initial KSM settings are restored. The [adaptive]
+// table describes the adaptive mode steps, and the [controller] table
+// the controller mode thresholds. The [budget] table describes the
+// budget mode, and the [advisor.<name>] tables the kernel scan advisor
+// settings of the advisor mode. The [kicks] table describes how kicks
+// are coalesced and rate limited. ScanBasis sets the pages the
+// pages_per_scan_factor settings apply to.
+type throttlerConfig struct {
+	ScanBasis  string                   `toml:"scan_basis"`
+	Mode       map[string]modeConfig    `toml:"mode"`
+	Throttle   []throttleStep           `toml:"throttle"`
+	Adaptive   *adaptiveConfig          `toml:"adaptive"`
+	Controller *controllerConfig        `toml:"controller"`
+	Budget     *budgetConfig            `toml:"budget"`
+	Advisor    map[string]advisorConfig `toml:"advisor"`
+	Kicks      *kicksConfig             `toml:"kicks"`
+}
+
+// modeConfig describes a KSM mode. Unset fields keep their defaults.
+type modeConfig struct {
+	PagesPerScanFactor *int64  `toml:"pages_per_scan_factor"`
+	ScanIntervalMS     *uint32 `toml:"scan_interval_ms"`
+	Run                *bool   `toml:"run"`
+
+	// Unmerge allows unmerging all pages to change busy knobs.
+	Unmerge *bool `toml:"unmerge"`
+
+	// Knobs sets advanced KSM attributes, e.g. merge_across_nodes.
+	Knobs map[string]interface{} `toml:"knobs"`
+}
+
+type throttleStep struct {
+	Mode string   `toml:"mode"`
+	Hold duration `toml:"hold"`
+}
+
+// adaptiveConfig describes the adaptive mode. Unset fields keep their
+// defaults.
+type adaptiveConfig struct {
+	Interval *duration             `toml:"interval"`
+	Hold     *duration             `toml:"hold"`
+	Level    []adaptiveLevelConfig `toml:"level"`
+}
+
+type adaptiveLevelConfig struct {
+	Mode           string  `toml:"mode"`
+	AvailableBelow float64 `toml:"available_below"`
+	PSIAbove       float64 `toml:"psi_above"`
+}
+
+// controllerConfig describes the controller mode. Unset fields keep
+// their defaults.
+type controllerConfig struct {
+	Interval    *duration `toml:"interval"`
+	MinMerged   *int64    `toml:"min_merged_per_scan"`
+	RaiseMerged *int64    `toml:"raise_merged_per_scan"`
+}
+
+// budgetConfig describes the budget mode. Unset fields keep their
+// defaults.
+type budgetConfig struct {
+	CPUPercent *float64  `toml:"cpu_percent"`
+	Interval   *duration `toml:"interval"`
+}
+
+// advisorConfig describes the kernel scan advisor settings for a KSM
+// mode. Unset fields keep their defaults.
+type advisorConfig struct {
+	TargetScanTime *duration `toml:"target_scan_time"`
+	MaxCPU         *uint32   `toml:"max_cpu"`
+}
+
+// kicksConfig describes how kicks are coalesced and rate limited. Unset
+// fields keep their defaults.
+type kicksConfig struct {
+	Window *duration `toml:"window"`
+	Rate   *float64  `toml:"rate"`
+	Burst  *int      `toml:"burst"`
+}
+
+// duration is a time.Duration parsed from strings like "30s" or "2m".
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalText(text []byte) error {
+	var err error
+
+	d.Duration, err = time.ParseDuration(string(text))
+
+	return err
+}
+
+func loadConfig(path string) (*throttlerConfig, error) {
+	var config throttlerConfig
+
+	md, err := toml.DecodeFile(path, &config)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid configuration file %s: %v", path, err)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		var keys []string
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+
+		return nil, fmt.Errorf("Unknown configuration keys in %s: %s", path, strings.Join(keys, ", "))
+	}
+
+	return &config, nil
+}
+
+// settingsMode checks that name is a KSM mode with settings.
+func settingsMode(name string) (ksmMode, error) {
+	mode, err := parseKSMMode(name)
+	if err != nil {
+		return "", err
+	}
+
+	if _, ok := ksmSettings[mode]; !ok {
+		return "", fmt.Errorf("KSM mode %q can not be configured", name)
+	}
+
+	return mode, nil
+}
+
+// knobsConfig returns the defaults knobs values updated with the
+// configuration file ones.
+func knobsConfig(defaults map[string]string, config map[string]interface{}) (map[string]string, error) {
+	knobs := make(map[string]string)
+	for name, value := range defaults {
+		knobs[name] = value
+	}
+
+	for name, value := range config {
+		if !isKSMKnob(name) {
+			return nil, fmt.Errorf("Unknown KSM knob %q", name)
+		}
+
+		switch v := value.(type) {
+		case string:
+			knobs[name] = v
+		case int64:
+			knobs[name] = fmt.Sprintf("%d", v)
+		case bool:
+			knobs[name] = "0"
+			if v {
+				knobs[name] = "1"
+			}
+		default:
+			return nil, fmt.Errorf("Invalid value %v for KSM knob %q", value, name)
+		}
+	}
+
+	return knobs, nil
+}
+
+// settings returns the default KSM settings updated with the
+// configuration file ones.
+func (c *throttlerConfig) settings() (map[ksmMode]ksmSetting, error) {
+	settings := make(map[ksmMode]ksmSetting)
+	for mode, setting := range ksmSettings {
+		settings[mode] = setting
+	}
+
+	for name, m := range c.Mode {
+		mode, err := settingsMode(name)
+		if err != nil {
+			return nil, err
+		}
+
+		setting := settings[mode]
+
+		if m.PagesPerScanFactor != nil {
+			if *m.PagesPerScanFactor <= 0 {
+				return nil, fmt.Errorf("Invalid pages_per_scan_factor %d for KSM mode %q",
+					*m.PagesPerScanFactor, name)
+			}
+			setting.pagesPerScanFactor = *m.PagesPerScanFactor
+		}
+
+		if m.ScanIntervalMS != nil {
+			setting.scanIntervalMS = *m.ScanIntervalMS
+		}
+
+		if m.Run != nil {
+			setting.run = *m.Run
+		}
+
+		if m.Unmerge != nil {
+			setting.unmerge = *m.Unmerge
+		}
+
+		if len(m.Knobs) > 0 {
+			knobs, err := knobsConfig(setting.knobs, m.Knobs)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid knobs for KSM mode %q: %v", name, err)
+			}
+			setting.knobs = knobs
+		}
+
+		settings[mode] = setting
+	}
+
+	return settings, nil
+}
+
+// throttleChain returns the throttling down intervals built from the
+// configuration file chain, the mode to move to when kicked and how
+// long to stay there.
+func (c *throttlerConfig) throttleChain() (map[ksmMode]ksmThrottleInterval, ksmMode, time.Duration, error) {
+	if len(c.Throttle) == 0 {
+		return nil, "", 0, fmt.Errorf("Empty throttle chain")
+	}
+
+	var modes []ksmMode
+	seen := make(map[ksmMode]bool)
+
+	for _, step := range c.Throttle {
+		mode, err := settingsMode(step.Mode)
+		if err != nil {
+			return nil, "", 0, err
+		}
+
+		// Going through the same mode twice would loop forever.
+		if seen[mode] {
+			return nil, "", 0, fmt.Errorf("Throttle chain cycle: KSM mode %q appears more than once", mode)
+		}
+		seen[mode] = true
+
+		if step.Hold.Duration <= 0 {
+			return nil, "", 0, fmt.Errorf("Invalid hold duration %v for KSM mode %q", step.Hold.Duration, mode)
+		}
+
+		modes = append(modes, mode)
+	}
+
+	intervals := map[ksmMode]ksmThrottleInterval{
+		// We stay here unless a new container shows up
+		ksmInitial: {interval: 0},
+	}
+
+	for i, mode := range modes {
+		if i == len(modes)-1 {
+			// From the last step: move to the initial settings and stop there
+			intervals[mode] = ksmThrottleInterval{
+				interval: 0,
+				nextKnob: ksmInitial,
+			}
+			break
+		}
+
+		intervals[mode] = ksmThrottleInterval{
+			interval: c.Throttle[i+1].Hold.Duration,
+			nextKnob: modes[i+1],
+		}
+	}
+
+	return intervals, modes[0], c.Throttle[0].Hold.Duration, nil
+}
+
+// adaptive returns the adaptive mode steps, sampling interval and
+// step down hold, updated with the configuration file ones.
+func (c *throttlerConfig) adaptive() ([]adaptiveLevel, time.Duration, time.Duration, error) {
+	levels := ksmAdaptiveLevels
+	interval := ksmAdaptiveInterval
+	hold := ksmAdaptiveHold
+
+	if c.Adaptive == nil {
+		return levels, interval, hold, nil
+	}
+
+	if c.Adaptive.Interval != nil {
+		if c.Adaptive.Interval.Duration <= 0 {
+			return nil, 0, 0, fmt.Errorf("Invalid adaptive interval %v", c.Adaptive.Interval.Duration)
+		}
+		interval = c.Adaptive.Interval.Duration
+	}
+
+	if c.Adaptive.Hold != nil {
+		if c.Adaptive.Hold.Duration < 0 {
+			return nil, 0, 0, fmt.Errorf("Invalid adaptive hold %v", c.Adaptive.Hold.Duration)
+		}
+		hold = c.Adaptive.Hold.Duration
+	}
+
+	if len(c.Adaptive.Level) == 0 {
+		return levels, interval, hold, nil
+	}
+
+	levels = nil
+	seen := make(map[ksmMode]bool)
+
+	for _, l := range c.Adaptive.Level {
+		mode, err := settingsMode(l.Mode)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		if seen[mode] {
+			return nil, 0, 0, fmt.Errorf("KSM mode %q appears more than once in the adaptive steps", mode)
+		}
+		seen[mode] = true
+
+		if l.AvailableBelow < 0 || l.AvailableBelow > 100 || l.PSIAbove < 0 || l.PSIAbove > 100 {
+			return nil, 0, 0, fmt.Errorf("Invalid adaptive thresholds for KSM mode %q, expecting percentages", mode)
+		}
+
+		levels = append(levels, adaptiveLevel{
+			mode:           mode,
+			availableBelow: l.AvailableBelow,
+			psiAbove:       l.PSIAbove,
+		})
+	}
+
+	return levels, interval, hold, nil
+}
+
+// controller returns the controller mode interval and thresholds,
+// updated with the configuration file ones.
+func (c *throttlerConfig) controller() (time.Duration, int64, int64, error) {
+	interval := ksmControllerInterval
+	minMerged := ksmControllerMinMerged
+	raiseMerged := ksmControllerRaiseMerged
+
+	if c.Controller == nil {
+		return interval, minMerged, raiseMerged, nil
+	}
+
+	if c.Controller.Interval != nil {
+		if c.Controller.Interval.Duration <= 0 {
+			return 0, 0, 0, fmt.Errorf("Invalid controller interval %v", c.Controller.Interval.Duration)
+		}
+		interval = c.Controller.Interval.Duration
+	}
+
+	if c.Controller.MinMerged != nil {
+		minMerged = *c.Controller.MinMerged
+	}
+
+	if c.Controller.RaiseMerged != nil {
+		raiseMerged = *c.Controller.RaiseMerged
+	}
+
+	if minMerged < 0 || raiseMerged <= minMerged {
+		return 0, 0, 0, fmt.Errorf("Invalid controller thresholds: min_merged_per_scan %d, raise_merged_per_scan %d",
+			minMerged, raiseMerged)
+	}
+
+	return interval, minMerged, raiseMerged, nil
+}
+
+// budget returns the budget mode CPU share and interval, updated with
+// the configuration file ones.
+func (c *throttlerConfig) budget() (float64, time.Duration, error) {
+	cpuPercent := ksmBudgetPercent
+	interval := ksmBudgetInterval
+
+	if c.Budget == nil {
+		return cpuPercent, interval, nil
+	}
+
+	if c.Budget.CPUPercent != nil {
+		if *c.Budget.CPUPercent <= 0 || *c.Budget.CPUPercent > 100 {
+			return 0, 0, fmt.Errorf("Invalid budget cpu_percent %v", *c.Budget.CPUPercent)
+		}
+		cpuPercent = *c.Budget.CPUPercent
+	}
+
+	if c.Budget.Interval != nil {
+		if c.Budget.Interval.Duration <= 0 {
+			return 0, 0, fmt.Errorf("Invalid budget interval %v", c.Budget.Interval.Duration)
+		}
+		interval = c.Budget.Interval.Duration
+	}
+
+	return cpuPercent, interval, nil
+}
+
+// advisorSettings returns the default scan advisor settings updated
+// with the configuration file ones.
+func (c *throttlerConfig) advisorSettings() (map[ksmMode]advisorSetting, error) {
+	settings := make(map[ksmMode]advisorSetting)
+	for mode, setting := range ksmAdvisorSettings {
+		settings[mode] = setting
+	}
+
+	for name, a := range c.Advisor {
+		mode, err := settingsMode(name)
+		if err != nil {
+			return nil, err
+		}
+
+		setting := settings[mode]
+
+		if a.TargetScanTime != nil {
+			if a.TargetScanTime.Duration < time.Second {
+				return nil, fmt.Errorf("Invalid advisor target_scan_time %v for KSM mode %q, expecting at least 1s",
+					a.TargetScanTime.Duration, name)
+			}
+			setting.targetScanTime = uint32(a.TargetScanTime.Duration / time.Second)
+		}
+
+		if a.MaxCPU != nil {
+			if *a.MaxCPU == 0 || *a.MaxCPU > 100 {
+				return nil, fmt.Errorf("Invalid advisor max_cpu %d for KSM mode %q", *a.MaxCPU, name)
+			}
+			setting.maxCPU = *a.MaxCPU
+		}
+
+		settings[mode] = setting
+	}
+
+	return settings, nil
+}
+
+// kicks returns the kick coalescing window and the kick rate limit,
+// updated with the configuration file ones.
+func (c *throttlerConfig) kicks() (time.Duration, float64, int, error) {
+	window := ksmKickWindow
+	rate := kickRate
+	burst := kickBurst
+
+	if c.Kicks == nil {
+		return window, rate, burst, nil
+	}
+
+	if c.Kicks.Window != nil {
+		if c.Kicks.Window.Duration < 0 {
+			return 0, 0, 0, fmt.Errorf("Invalid kicks window %v", c.Kicks.Window.Duration)
+		}
+		window = c.Kicks.Window.Duration
+	}
+
+	if c.Kicks.Rate != nil {
+		if *c.Kicks.Rate < 0 {
+			return 0, 0, 0, fmt.Errorf("Invalid kicks rate %v", *c.Kicks.Rate)
+		}
+		rate = *c.Kicks.Rate
+	}
+
+	if c.Kicks.Burst != nil {
+		burst = *c.Kicks.Burst
+	}
+
+	if rate > 0 && burst < 1 {
+		return 0, 0, 0, fmt.Errorf("Invalid kicks burst %d, expecting at least 1", burst)
+	}
+
+	return window, rate, burst, nil
+}
+
+// apply validates the configuration and replaces the default KSM
+// settings, throttling chain, adaptive steps, controller thresholds,
+// budget, scan advisor settings and kick limits with it.
+func (c *throttlerConfig) apply() error {
+	basis := ksmScanBasis
+	if c.ScanBasis != "" {
+		b, err := parseScanBasis(c.ScanBasis)
+		if err != nil {
+			return err
+		}
+
+		basis = b
+	}
+
+	settings, err := c.settings()
+	if err != nil {
+		return err
+	}
+
+	levels, adaptiveInterval, adaptiveHold, err := c.adaptive()
+	if err != nil {
+		return err
+	}
+
+	controllerInterval, minMerged, raiseMerged, err := c.controller()
+	if err != nil {
+		return err
+	}
+
+	budgetPercent, budgetInterval, err := c.budget()
+	if err != nil {
+		return err
+	}
+
+	advisorSettings, err := c.advisorSettings()
+	if err != nil {
+		return err
+	}
+
+	kickWindow, rate, burst, err := c.kicks()
+	if err != nil {
+		return err
+	}
+
+	if len(c.Throttle) > 0 {
+		intervals, kickMode, kickInterval, err := c.throttleChain()
+		if err != nil {
+			return err
+		}
+
+		ksmThrottleIntervals = intervals
+		ksmKickMode = kickMode
+		ksmAggressiveInterval = kickInterval
+	}
+
+	ksmScanBasis = basis
+	ksmSettings = settings
+	ksmAdaptiveLevels = levels
+	ksmAdaptiveInterval = adaptiveInterval
+	ksmAdaptiveHold = adaptiveHold
+	ksmControllerInterval = controllerInterval
+	ksmControllerMinMerged = minMerged
+	ksmControllerRaiseMerged = raiseMerged
+	ksmBudgetPercent = budgetPercent
+	ksmBudgetInterval = budgetInterval
+	ksmAdvisorSettings = advisorSettings
+	ksmKickWindow = kickWindow
+	kickRate = rate
+	kickBurst = burst
+
+	return nil
+}
@@ -0,0 +1,278 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const exampleConfig = "kata-ksm-throttler.toml"
+
+func writeTestConfig(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "ksmthrottler-config")
+	assert.Nil(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString(content)
+	assert.Nil(t, err)
+
+	return f.Name()
+}
+
+func TestConfigExample(t *testing.T) {
+	config, err := loadConfig(exampleConfig)
+	assert.Nil(t, err)
+
+	// The example configuration describes the built-in defaults
+	assert.Equal(t, string(ksmScanBasis), config.ScanBasis)
+
+	settings, err := config.settings()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmSettings, settings)
+
+	intervals, kickMode, kickInterval, err := config.throttleChain()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmAggressive, kickMode)
+	assert.Equal(t, 30*time.Second, kickInterval)
+	assert.Equal(t, ksmThrottleIntervals, intervals)
+
+	levels, interval, hold, err := config.adaptive()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmAdaptiveLevels, levels)
+	assert.Equal(t, ksmAdaptiveInterval, interval)
+	assert.Equal(t, ksmAdaptiveHold, hold)
+
+	controllerInterval, minMerged, raiseMerged, err := config.controller()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmControllerInterval, controllerInterval)
+	assert.Equal(t, ksmControllerMinMerged, minMerged)
+	assert.Equal(t, ksmControllerRaiseMerged, raiseMerged)
+
+	budgetPercent, budgetInterval, err := config.budget()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmBudgetPercent, budgetPercent)
+	assert.Equal(t, ksmBudgetInterval, budgetInterval)
+
+	advisorSettings, err := config.advisorSettings()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmAdvisorSettings, advisorSettings)
+
+	kickWindow, rate, burst, err := config.kicks()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmKickWindow, kickWindow)
+	assert.Equal(t, kickRate, rate)
+	assert.Equal(t, kickBurst, burst)
+}
+
+func TestConfigApply(t *testing.T) {
+	savedSettings := ksmSettings
+	savedIntervals := ksmThrottleIntervals
+	savedKickMode := ksmKickMode
+	savedInterval := ksmAggressiveInterval
+	savedBasis := ksmScanBasis
+	defer func() {
+		ksmScanBasis = savedBasis
+		ksmSettings = savedSettings
+		ksmThrottleIntervals = savedIntervals
+		ksmKickMode = savedKickMode
+		ksmAggressiveInterval = savedInterval
+	}()
+
+	path := writeTestConfig(t, `
+scan_basis = "mergeable"
+
+[mode.standard]
+scan_interval_ms = 20
+
+[[throttle]]
+mode = "standard"
+hold = "1m"
+
+[[throttle]]
+mode = "slow"
+hold = "10m"
+`)
+	defer os.Remove(path)
+
+	config, err := loadConfig(path)
+	assert.Nil(t, err)
+
+	err = config.apply()
+	assert.Nil(t, err)
+
+	assert.Equal(t, scanBasisMergeable, ksmScanBasis)
+	assert.Equal(t, ksmSetting{100, 20, true, nil, false}, ksmSettings[ksmStandard])
+	assert.Equal(t, savedSettings[ksmAggressive], ksmSettings[ksmAggressive])
+	assert.Equal(t, ksmStandard, ksmKickMode)
+	assert.Equal(t, time.Minute, ksmAggressiveInterval)
+	assert.Equal(t, ksmThrottleInterval{10 * time.Minute, ksmSlow}, ksmThrottleIntervals[ksmStandard])
+	assert.Equal(t, ksmThrottleInterval{0, ksmInitial}, ksmThrottleIntervals[ksmSlow])
+
+	_, ok := ksmThrottleIntervals[ksmAggressive]
+	assert.False(t, ok)
+}
+
+func TestConfigKnobs(t *testing.T) {
+	path := writeTestConfig(t, `
+[mode.aggressive]
+unmerge = true
+
+[mode.aggressive.knobs]
+merge_across_nodes = 0
+use_zero_pages = true
+advisor_mode = "scan-time"
+`)
+	defer os.Remove(path)
+
+	config, err := loadConfig(path)
+	assert.Nil(t, err)
+
+	settings, err := config.settings()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		ksmMergeAcrossNodes: "0",
+		ksmUseZeroPages:     "1",
+		ksmAdvisorMode:      "scan-time",
+	}, settings[ksmAggressive].knobs)
+	assert.True(t, settings[ksmAggressive].unmerge)
+	assert.Nil(t, settings[ksmStandard].knobs)
+	assert.False(t, settings[ksmStandard].unmerge)
+}
+
+func TestConfigInvalid(t *testing.T) {
+	configs := map[string]string{
+		"unknown scan basis": `
+scan_basis = "rss"
+`,
+		"unknown mode": `
+[mode.turbo]
+run = true
+`,
+		"unknown key": `
+[mode.slow]
+speed = 3
+`,
+		"invalid factor": `
+[mode.slow]
+pages_per_scan_factor = 0
+`,
+		"unknown chain mode": `
+[[throttle]]
+mode = "auto"
+hold = "1m"
+`,
+		"cycle": `
+[[throttle]]
+mode = "aggressive"
+hold = "1m"
+
+[[throttle]]
+mode = "slow"
+hold = "1m"
+
+[[throttle]]
+mode = "aggressive"
+hold = "1m"
+`,
+		"missing hold": `
+[[throttle]]
+mode = "aggressive"
+`,
+		"invalid hold": `
+[[throttle]]
+mode = "aggressive"
+hold = "soon"
+`,
+		"invalid adaptive interval": `
+[adaptive]
+interval = "0s"
+`,
+		"unknown adaptive mode": `
+[[adaptive.level]]
+mode = "adaptive"
+available_below = 10.0
+`,
+		"repeated adaptive mode": `
+[[adaptive.level]]
+mode = "slow"
+available_below = 50.0
+
+[[adaptive.level]]
+mode = "slow"
+available_below = 10.0
+`,
+		"invalid adaptive threshold": `
+[[adaptive.level]]
+mode = "slow"
+available_below = 150.0
+`,
+		"invalid controller interval": `
+[controller]
+interval = "-1s"
+`,
+		"invalid controller thresholds": `
+[controller]
+min_merged_per_scan = 100
+raise_merged_per_scan = 10
+`,
+		"unknown knob": `
+[mode.slow.knobs]
+turbo = 1
+`,
+		"invalid knob value": `
+[mode.slow.knobs]
+max_page_sharing = 1.5
+`,
+		"invalid advisor mode": `
+[advisor.initial]
+max_cpu = 10
+`,
+		"invalid advisor scan time": `
+[advisor.slow]
+target_scan_time = "10ms"
+`,
+		"invalid advisor cpu": `
+[advisor.slow]
+max_cpu = 0
+`,
+		"invalid budget": `
+[budget]
+cpu_percent = 0.0
+`,
+		"invalid kicks window": `
+[kicks]
+window = "-1s"
+`,
+		"invalid kicks burst": `
+[kicks]
+rate = 10.0
+burst = 0
+`,
+	}
+
+	for name, content := range configs {
+		path := writeTestConfig(t, content)
+		defer os.Remove(path)
+
+		config, err := loadConfig(path)
+		if err == nil {
+			err = config.apply()
+		}
+
+		assert.NotNil(t, err, "%s configuration should be rejected", name)
+	}
+}
+
+func TestConfigMissingFile(t *testing.T) {
+	_, err := loadConfig("/does/not/exist.toml")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,225 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ksmControllerInterval is how often the controller checks the KSM
+// merging counters.
+var ksmControllerInterval = 5 * time.Second
+
+// ksmControllerMinMerged is the number of newly merged pages per full
+// scan below which the controller backs off to the next mode of the
+// throttling down chain.
+var ksmControllerMinMerged int64 = 64
+
+// ksmControllerRaiseMerged is the number of newly merged pages per full
+// scan from which the controller moves back up the throttling down chain.
+var ksmControllerRaiseMerged int64 = 4096
+
+// controllerChain returns the throttling down chain modes, from the
+// kick mode down to the last mode before the initial settings.
+func controllerChain() []ksmMode {
+	var chain []ksmMode
+	seen := make(map[ksmMode]bool)
+
+	for mode := ksmKickMode; mode != ksmInitial && !seen[mode]; mode = ksmThrottleIntervals[mode].nextKnob {
+		if _, ok := ksmSettings[mode]; !ok {
+			break
+		}
+
+		seen[mode] = true
+		chain = append(chain, mode)
+	}
+
+	return chain
+}
+
+type controllerState struct {
+	chain []ksmMode
+
+	// step is our position in the chain. It is past the chain end
+	// when we're back to the initial settings.
+	step int
+
+	// last holds the counters we compute the deltas from.
+	last ksmStats
+
+	// Kicks keep us on holdStep or above until holdDeadline.
+	holdStep     int
+	holdDeadline time.Time
+}
+
+func (c *controllerState) stepMode(step int) ksmMode {
+	if step >= len(c.chain) {
+		return ksmInitial
+	}
+
+	return c.chain[step]
+}
+
+func (c *controllerState) moveTo(k *ksm, step int) {
+	mode := c.stepMode(step)
+
+	if err := k.moveTo(mode); err != nil {
+		throttlerLog.WithError(err).WithField("ksm-mode", mode).Error("controller failed to tune")
+		return
+	}
+
+	c.step = step
+
+	// The counters of the previous setting do not tell how this one
+	// merges.
+	c.baseline(k)
+}
+
+// kickStep returns the chain step a kick to mode moves us to. Kicks
+// never move us down the chain, and modes that are not part of it
+// move us to its head.
+func (c *controllerState) kickStep(mode ksmMode) int {
+	step := 0
+	for i, m := range c.chain {
+		if m == mode {
+			step = i
+			break
+		}
+	}
+
+	if c.step < step {
+		return c.step
+	}
+
+	return step
+}
+
+// kick moves us to the chain step of a kick, and holds it for the kick
+// hold duration. A kick never shortens nor lowers a pending hold.
+func (c *controllerState) kick(k *ksm, kick ksmKick) {
+	now := time.Now()
+	step := c.kickStep(kick.mode)
+
+	if now.After(c.holdDeadline) || step < c.holdStep {
+		c.holdStep = step
+	}
+
+	if deadline := now.Add(kick.hold); deadline.After(c.holdDeadline) {
+		c.holdDeadline = deadline
+	}
+
+	k.Lock()
+	k.throttleDeadline = c.holdDeadline
+	k.Unlock()
+
+	c.moveTo(k, step)
+}
+
+// holding tells whether a kick keeps us from backing off.
+func (c *controllerState) holding(k *ksm) bool {
+	if c.holdDeadline.IsZero() {
+		return false
+	}
+
+	if time.Now().Before(c.holdDeadline) {
+		return c.step >= c.holdStep
+	}
+
+	c.holdDeadline = time.Time{}
+
+	k.Lock()
+	k.throttleDeadline = time.Time{}
+	k.Unlock()
+
+	return false
+}
+
+// baseline resets the counters we compute the deltas from.
+func (c *controllerState) baseline(k *ksm) {
+	s, err := k.stats()
+	if err != nil {
+		throttlerLog.WithError(err).Error("could not get KSM stats")
+		return
+	}
+
+	c.last = s
+}
+
+// check compares the pages merged since the last full scans with our
+// thresholds, and moves along the chain accordingly.
+func (c *controllerState) check(k *ksm) {
+	// We're waiting for a kick
+	if c.step >= len(c.chain) {
+		return
+	}
+
+	s, err := k.stats()
+	if err != nil {
+		throttlerLog.WithError(err).Error("could not get KSM stats")
+		return
+	}
+
+	scans := s.fullScans - c.last.fullScans
+	if scans <= 0 {
+		// Wait for ksmd to complete a full scan
+		return
+	}
+
+	merged := (s.pagesSharing - c.last.pagesSharing) / scans
+	unshared := (s.pagesUnshared - c.last.pagesUnshared) / scans
+	c.last = s
+
+	logger := throttlerLog.WithFields(logrus.Fields{
+		"ksm-mode":          c.stepMode(c.step),
+		"full-scans":        scans,
+		"merged-per-scan":   merged,
+		"unshared-per-scan": unshared,
+	})
+
+	switch {
+	case merged < ksmControllerMinMerged && c.holding(k):
+		logger.Debug("ksmd is not merging enough, holding the kick mode")
+
+	case merged < ksmControllerMinMerged:
+		logger.Debug("ksmd is not merging enough, backing off")
+		c.moveTo(k, c.step+1)
+
+	case merged >= ksmControllerRaiseMerged && c.step > 0:
+		logger.Debug("ksmd keeps merging, moving up")
+		c.moveTo(k, c.step-1)
+	}
+}
+
+// controllerLoop moves KSM along the throttling down chain depending on
+// how many pages ksmd merges, until stop is closed. Kicks move us back
+// to the head of the chain.
+func (k *ksm) controllerLoop(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(ksmControllerInterval)
+	defer ticker.Stop()
+
+	c := controllerState{chain: controllerChain()}
+	c.step = len(c.chain)
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case <-k.kickChannel:
+			if kick, ok := k.takeKick(); ok {
+				c.kick(k, kick)
+			}
+
+		case <-ticker.C:
+			c.check(k)
+		}
+	}
+}
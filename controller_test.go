@@ -0,0 +1,129 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setControllerCounters(t *testing.T, fullScans, pagesSharing int64) {
+	err := writeKSMCounters(map[string]string{
+		ksmPagesShared:   "0",
+		ksmPagesSharing:  fmt.Sprintf("%d", pagesSharing),
+		ksmPagesUnshared: "0",
+		ksmPagesVolatile: "0",
+		ksmFullScans:     fmt.Sprintf("%d", fullScans),
+	})
+	assert.Nil(t, err)
+}
+
+func TestControllerChain(t *testing.T) {
+	assert.Equal(t, []ksmMode{ksmAggressive, ksmStandard, ksmSlow}, controllerChain())
+}
+
+func TestControllerKickStep(t *testing.T) {
+	c := controllerState{chain: controllerChain()}
+	c.step = len(c.chain)
+
+	assert.Equal(t, 1, c.kickStep(ksmStandard))
+	assert.Equal(t, 0, c.kickStep(ksmOff))
+
+	// Kicks never move us down the chain
+	c.step = 0
+	assert.Equal(t, 0, c.kickStep(ksmSlow))
+}
+
+func TestKSMControllerMode(t *testing.T) {
+	savedInterval := ksmControllerInterval
+	defer func() {
+		ksmControllerInterval = savedInterval
+	}()
+
+	ksmControllerInterval = 20 * time.Millisecond
+
+	counters := map[string]string{
+		ksmPagesShared:   "",
+		ksmPagesSharing:  "",
+		ksmPagesUnshared: "",
+		ksmPagesVolatile: "",
+		ksmFullScans:     "",
+	}
+	defer removeKSMCounters(counters)
+
+	setControllerCounters(t, 0, 0)
+
+	k, err := startKSM(defaultKSMRoot, ksmController)
+	assert.Nil(t, err)
+	defer k.restore()
+
+	s, err := k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmController, s.policy)
+	assert.Equal(t, ksmInitial, s.knob)
+	assert.True(t, s.throttling)
+
+	// Kicks move us to the head of the chain
+	k.kickWith(ksmKick{mode: ksmAggressive, hold: 50 * time.Millisecond})
+	assert.True(t, waitForKnob(k, ksmAggressive, time.Second))
+
+	// No full scan completed yet, we hold
+	time.Sleep(5 * ksmControllerInterval)
+	s, err = k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmAggressive, s.knob)
+
+	// ksmd keeps merging, we hold
+	setControllerCounters(t, 1, 10000)
+	time.Sleep(5 * ksmControllerInterval)
+	s, err = k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmAggressive, s.knob)
+
+	// Nothing new to merge, we back off
+	setControllerCounters(t, 2, 10010)
+	assert.True(t, waitForKnob(k, ksmStandard, time.Second))
+
+	// ksmd merges a lot again, we move back up
+	setControllerCounters(t, 3, 20010)
+	assert.True(t, waitForKnob(k, ksmAggressive, time.Second))
+
+	// And back off to the initial settings
+	setControllerCounters(t, 4, 20010)
+	assert.True(t, waitForKnob(k, ksmStandard, time.Second))
+	setControllerCounters(t, 5, 20010)
+	assert.True(t, waitForKnob(k, ksmSlow, time.Second))
+	setControllerCounters(t, 6, 20010)
+	assert.True(t, waitForKnob(k, ksmInitial, time.Second))
+
+	// Kicks hold their mode, even when ksmd is not merging enough
+	k.kickWith(ksmKick{mode: ksmStandard, hold: time.Hour})
+	assert.True(t, waitForKnob(k, ksmStandard, time.Second))
+
+	setControllerCounters(t, 7, 20010)
+	time.Sleep(5 * ksmControllerInterval)
+	s, err = k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmStandard, s.knob)
+	assert.True(t, s.remaining > 59*time.Minute)
+
+	// But they do not keep us from moving up
+	setControllerCounters(t, 8, 30010)
+	assert.True(t, waitForKnob(k, ksmAggressive, time.Second))
+
+	// And we back off to the kick mode only
+	setControllerCounters(t, 9, 30010)
+	assert.True(t, waitForKnob(k, ksmStandard, time.Second))
+	setControllerCounters(t, 10, 30010)
+	time.Sleep(5 * ksmControllerInterval)
+	s, err = k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmStandard, s.knob)
+}
@@ -0,0 +1,121 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+type ksmEventType string
+
+const (
+	ksmEventKick       ksmEventType = "kick"
+	ksmEventTransition ksmEventType = "transition"
+	ksmEventRestore    ksmEventType = "restore"
+	ksmEventError      ksmEventType = "error"
+)
+
+// ksmEventBacklog is how many events a watcher can lag behind before
+// we start dropping its events.
+const ksmEventBacklog = 64
+
+// ksmEvent describes something the throttler did.
+type ksmEvent struct {
+	kind ksmEventType
+	time time.Time
+
+	// oldMode and newMode are set for transitions. newMode is also
+	// set when restoring the initial settings.
+	oldMode ksmMode
+	newMode ksmMode
+
+	// values are the sysfs values after a transition or a restore.
+	values ksmValues
+
+	// kick is set for kicks.
+	kick ksmKick
+
+	// err is set for errors.
+	err error
+}
+
+// eventBroker fans the throttler events out to its watchers.
+type eventBroker struct {
+	sync.Mutex
+
+	watchers map[chan ksmEvent]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		watchers: make(map[chan ksmEvent]struct{}),
+	}
+}
+
+// subscribe returns a channel receiving all the events published from
+// now on, and a function to call when done with it.
+func (b *eventBroker) subscribe() (<-chan ksmEvent, func()) {
+	events := make(chan ksmEvent, ksmEventBacklog)
+
+	b.Lock()
+	b.watchers[events] = struct{}{}
+	b.Unlock()
+
+	return events, func() {
+		b.Lock()
+		delete(b.watchers, events)
+		b.Unlock()
+	}
+}
+
+// count returns the number of watchers.
+func (b *eventBroker) count() int {
+	b.Lock()
+	defer b.Unlock()
+
+	return len(b.watchers)
+}
+
+// publish sends an event to all watchers. It never blocks: Watchers
+// that lag too far behind miss events.
+func (b *eventBroker) publish(e ksmEvent) {
+	if e.time.IsZero() {
+		e.time = time.Now()
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	for events := range b.watchers {
+		select {
+		case events <- e:
+		default:
+			throttlerLog.WithField("event", e.kind).Debug("Watcher lagging behind, dropping event")
+		}
+	}
+}
+
+// publishError is unlocked. You should take the ksm lock before calling it.
+func (k *ksm) publishError(err error) {
+	k.events.publish(ksmEvent{
+		kind: ksmEventError,
+		err:  err,
+	})
+}
+
+// publishValues is unlocked. You should take the ksm lock before calling it.
+// It publishes an event carrying the current sysfs values.
+func (k *ksm) publishValues(e ksmEvent) {
+	values, err := k.currentValues()
+	if err != nil {
+		throttlerLog.WithError(err).Error("could not read KSM values")
+	}
+
+	e.values = values
+	k.events.publish(e)
+}
@@ -0,0 +1,105 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nextEvent(t *testing.T, events <-chan ksmEvent) ksmEvent {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatalf("No event received")
+	}
+
+	return ksmEvent{}
+}
+
+func TestEventBroker(t *testing.T) {
+	b := newEventBroker()
+
+	events, cancel := b.subscribe()
+	others, cancelOthers := b.subscribe()
+	defer cancelOthers()
+
+	b.publish(ksmEvent{kind: ksmEventError, err: errors.New("failure")})
+
+	e := nextEvent(t, events)
+	assert.Equal(t, ksmEventError, e.kind)
+	assert.False(t, e.time.IsZero())
+	assert.Equal(t, e, nextEvent(t, others))
+
+	cancel()
+	b.publish(ksmEvent{kind: ksmEventKick})
+	assert.Equal(t, 0, len(events))
+	assert.Equal(t, 1, len(others))
+
+	// Lagging watchers miss events, without blocking us
+	for i := 0; i < 2*ksmEventBacklog; i++ {
+		b.publish(ksmEvent{kind: ksmEventKick})
+	}
+	assert.Equal(t, ksmEventBacklog, len(others))
+}
+
+func TestKSMEvents(t *testing.T) {
+	// Let's make the throttling down faster, for quicker tests purpose.
+	ksmAggressiveInterval = 100 * time.Millisecond
+
+	k := initKSM(defaultKSMRoot, t)
+	defer k.restore()
+
+	events, cancel := k.events.subscribe()
+	defer cancel()
+
+	k.throttle()
+	k.kickWith(ksmKick{mode: ksmAggressive, hold: ksmAggressiveInterval, reason: "test"})
+
+	e := nextEvent(t, events)
+	assert.Equal(t, ksmEventKick, e.kind)
+	assert.Equal(t, "test", e.kick.reason)
+
+	e = nextEvent(t, events)
+	assert.Equal(t, ksmEventTransition, e.kind)
+	assert.Equal(t, ksmInitial, e.oldMode)
+	assert.Equal(t, ksmAggressive, e.newMode)
+	assert.Equal(t, ksmStart, e.values.run)
+	assert.Equal(t, "1", e.values.sleepInterval)
+
+	e = nextEvent(t, events)
+	assert.Equal(t, ksmEventTransition, e.kind)
+	assert.Equal(t, ksmAggressive, e.oldMode)
+	assert.Equal(t, ksmStandard, e.newMode)
+	assert.Equal(t, "10", e.values.sleepInterval)
+
+	err := k.setMode(ksmInitial)
+	assert.Nil(t, err)
+
+	e = nextEvent(t, events)
+	assert.Equal(t, ksmEventRestore, e.kind)
+	assert.Equal(t, k.initialSleepInterval, e.values.sleepInterval)
+
+	e = nextEvent(t, events)
+	assert.Equal(t, ksmEventTransition, e.kind)
+	assert.Equal(t, ksmInitial, e.newMode)
+
+	// Tuning failures are published
+	setting := ksmSettings[ksmStandard]
+	setting.pagesPerScanFactor = 0
+
+	err = k.tune(setting)
+	assert.NotNil(t, err)
+
+	e = nextEvent(t, events)
+	assert.Equal(t, ksmEventError, e.kind)
+	assert.Equal(t, err, e.err)
+}
@@ -0,0 +1,224 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Advanced KSM sysfs attributes. They all depend on the kernel version
+// and configuration, see Documentation/admin-guide/mm/ksm.rst.
+const (
+	ksmMergeAcrossNodes      = "merge_across_nodes"
+	ksmMaxPageSharing        = "max_page_sharing"
+	ksmStableNodeChainsPrune = "stable_node_chains_prune_millisecs"
+	ksmUseZeroPages          = "use_zero_pages"
+	ksmSmartScan             = "smart_scan"
+	ksmAdvisorMaxCPU         = "advisor_max_cpu"
+	ksmAdvisorTargetScanTime = "advisor_target_scan_time"
+	ksmAdvisorMode           = "advisor_mode"
+	ksmUnmerge               = "2"
+
+	ksmAdvisorNone     = "none"
+	ksmAdvisorScanTime = "scan-time"
+)
+
+// ksmKnobs lists the advanced KSM attributes we manage, in the order we
+// write them. The advisor parameters come before the advisor mode.
+var ksmKnobs = []string{
+	ksmMergeAcrossNodes,
+	ksmMaxPageSharing,
+	ksmStableNodeChainsPrune,
+	ksmUseZeroPages,
+	ksmSmartScan,
+	ksmAdvisorMaxCPU,
+	ksmAdvisorTargetScanTime,
+	ksmAdvisorMode,
+}
+
+// ksmKnob is an advanced KSM attribute exported by the running kernel.
+type ksmKnob struct {
+	attr    sysfsAttribute
+	initial string
+}
+
+func isKSMKnob(name string) bool {
+	for _, n := range ksmKnobs {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// knobValue returns the value of a KSM attribute as we should write it
+// back. Multiple choice attributes like advisor_mode read as
+// "[none] scan-time", the bracketed value being the current one.
+func knobValue(value string) string {
+	value = strings.TrimSpace(value)
+
+	start := strings.Index(value, "[")
+	end := strings.Index(value, "]")
+	if start < 0 || end < start {
+		return value
+	}
+
+	return value[start+1 : end]
+}
+
+// openKnobs opens and captures the initial values of the advanced KSM
+// attributes the running kernel exports.
+func (k *ksm) openKnobs() error {
+	k.knobs = make(map[string]*ksmKnob)
+
+	for _, name := range ksmKnobs {
+		knob := &ksmKnob{
+			attr: sysfsAttribute{
+				path: filepath.Join(k.root, name),
+			},
+		}
+
+		if err := knob.attr.open(); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			k.closeKnobs()
+			return err
+		}
+
+		value, err := knob.attr.read()
+		if err != nil {
+			_ = knob.attr.close()
+			k.closeKnobs()
+			return err
+		}
+
+		knob.initial = knobValue(value)
+		k.knobs[name] = knob
+	}
+
+	return nil
+}
+
+func (k *ksm) closeKnobs() {
+	for name, knob := range k.knobs {
+		_ = knob.attr.close()
+		delete(k.knobs, name)
+	}
+}
+
+// writeKnob is unlocked. You should take the ksm lock before calling it.
+// Knobs like merge_across_nodes can only change when no page is shared:
+// With unmerge, we then unmerge all pages first, otherwise we fail.
+func (k *ksm) writeKnob(name, value string, unmerge bool) error {
+	knob, ok := k.knobs[name]
+	if !ok {
+		throttlerLog.WithField("knob", name).Debug("KSM knob not available, skipping")
+		return nil
+	}
+
+	err := knob.attr.write(value)
+
+	// Unmerging all pages may use a lot of memory at once, we only
+	// do it when the KSM mode asks for it.
+	if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == syscall.EBUSY && unmerge {
+		throttlerLog.WithField("knob", name).Warn("KSM knob busy, unmerging all pages")
+
+		if err := k.run.write(ksmUnmerge); err != nil {
+			return err
+		}
+
+		err = knob.attr.write(value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Could not set KSM %s to %s: %v", name, value, err)
+	}
+
+	return nil
+}
+
+// writeKnobs is unlocked. You should take the ksm lock before calling it.
+func (k *ksm) writeKnobs(values map[string]string, unmerge bool) error {
+	for _, name := range ksmKnobs {
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+
+		if err := k.writeKnob(name, value, unmerge); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreKnobs is unlocked. You should take the ksm lock before calling it.
+func (k *ksm) restoreKnobs(unmerge bool) error {
+	return k.writeKnobs(k.initialKnobs(), unmerge)
+}
+
+// settingKnobs returns the knobs values for a KSM setting: The ones the
+// setting does not set keep their initial values.
+func (k *ksm) settingKnobs(knobs map[string]string) map[string]string {
+	values := k.initialKnobs()
+
+	for name, value := range knobs {
+		values[name] = value
+	}
+
+	return values
+}
+
+// advising is unlocked. You should take the ksm lock before calling it.
+// It tells if the kernel scan advisor tunes pages_to_scan.
+func (k *ksm) advising() bool {
+	knob, ok := k.knobs[ksmAdvisorMode]
+	if !ok {
+		return false
+	}
+
+	value, err := knob.attr.read()
+	if err != nil {
+		return false
+	}
+
+	return knobValue(value) != ksmAdvisorNone
+}
+
+func (k *ksm) initialKnobs() map[string]string {
+	values := make(map[string]string)
+
+	for name, knob := range k.knobs {
+		values[name] = knob.initial
+	}
+
+	return values
+}
+
+// currentKnobs is unlocked. You should take the ksm lock before calling it.
+func (k *ksm) currentKnobs() (map[string]string, error) {
+	values := make(map[string]string)
+
+	for name, knob := range k.knobs {
+		value, err := knob.attr.read()
+		if err != nil {
+			return nil, err
+		}
+
+		values[name] = knobValue(value)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,96 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readTestKnob(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(defaultKSMRoot, name))
+	assert.Nil(t, err)
+
+	return strings.TrimSpace(string(data))
+}
+
+func TestKnobValue(t *testing.T) {
+	assert.Equal(t, "1", knobValue("1\n"))
+	assert.Equal(t, "none", knobValue("[none] scan-time\n"))
+	assert.Equal(t, "scan-time", knobValue("none [scan-time]\n"))
+}
+
+func TestKSMKnobs(t *testing.T) {
+	knobs := map[string]string{
+		ksmMergeAcrossNodes: "1",
+		ksmUseZeroPages:     "0",
+		ksmAdvisorMode:      "[none] scan-time",
+	}
+
+	err := writeKSMCounters(knobs)
+	defer removeKSMCounters(knobs)
+	assert.Nil(t, err)
+
+	k := initKSM(defaultKSMRoot, t)
+	defer k.restore()
+
+	// Only the knobs the kernel exports are managed
+	assert.Equal(t, 3, len(k.knobs))
+
+	s, err := k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		ksmMergeAcrossNodes: "1",
+		ksmUseZeroPages:     "0",
+		ksmAdvisorMode:      "none",
+	}, s.initial.knobs)
+	assert.Equal(t, s.initial.knobs, s.current.knobs)
+
+	setting := ksmSettings[ksmAggressive]
+	setting.knobs = map[string]string{
+		ksmMergeAcrossNodes: "0",
+		ksmUseZeroPages:     "1",
+		ksmAdvisorMode:      "scan-time",
+		ksmSmartScan:        "1",
+	}
+
+	err = k.tune(setting)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "0", readTestKnob(t, ksmMergeAcrossNodes))
+	assert.Equal(t, "1", readTestKnob(t, ksmUseZeroPages))
+	assert.Equal(t, "scan-time", readTestKnob(t, ksmAdvisorMode))
+
+	s, err = k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, "0", s.current.knobs[ksmMergeAcrossNodes])
+	_, ok := s.current.knobs[ksmSmartScan]
+	assert.False(t, ok)
+
+	// Knobs are also set when turning KSM off, and the ones a
+	// setting does not set get back to their initial values.
+	setting = ksmSettings[ksmOff]
+	setting.knobs = map[string]string{ksmUseZeroPages: "0"}
+
+	err = k.tune(setting)
+	assert.Nil(t, err)
+	assert.Equal(t, "0", readTestKnob(t, ksmUseZeroPages))
+	assert.Equal(t, "1", readTestKnob(t, ksmMergeAcrossNodes))
+	assert.Equal(t, "none", readTestKnob(t, ksmAdvisorMode))
+
+	err = k.restore()
+	assert.Nil(t, err)
+
+	assert.Equal(t, "1", readTestKnob(t, ksmMergeAcrossNodes))
+	assert.Equal(t, "0", readTestKnob(t, ksmUseZeroPages))
+	assert.Equal(t, "none", readTestKnob(t, ksmAdvisorMode))
+	assert.Equal(t, 0, len(k.knobs))
+}
@@ -13,7 +13,6 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -21,7 +20,6 @@ import (
 	"syscall"
 	"time"
 
-	ksig "github.com/kata-containers/ksm-throttler/pkg/signals"
 	"github.com/sirupsen/logrus"
 )
 
@@ -29,7 +27,8 @@ type ksmSetting struct {
 	// pagesPerScanFactor describes how many pages we want
 	// to scan per KSM run.
 	// ksmd will san N pages, where N*pagesPerScanFactor is
-	// equal to the number of anonymous pages.
+	// equal to the number of anonymous pages, or of mergeable
+	// pages with the mergeable scan basis.
 	pagesPerScanFactor int64
 
 	// scanIntervalMS is the KSM scan interval in milliseconds.
@@ -37,52 +36,139 @@ type ksmSetting struct {
 
 	// run describes if we want KSM to be on or off.
 	run bool
+
+	// knobs are the advanced KSM attributes values we want,
+	// when the running kernel supports them.
+	knobs map[string]string
+
+	// unmerge allows unmerging all pages when a knob can only change
+	// while no page is shared, e.g. merge_across_nodes.
+	unmerge bool
 }
 
-func anonPages() (int64, error) {
+// readMemInfo returns the meminfo values, in kB.
+func readMemInfo() (map[string]int64, error) {
 	// We're going to parse meminfo
 	f, err := os.Open(memInfo)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 	defer f.Close()
 
+	values := make(map[string]int64)
+
 	scan := bufio.NewScanner(f)
 	for scan.Scan() {
-		line := scan.Text()
-
-		// We only care about anonymous pages
-		if !strings.HasPrefix(line, "AnonPages:") {
+		// e.g. "AnonPages:        123456 kB"
+		fields := strings.Fields(scan.Text())
+		if len(fields) < 2 {
 			continue
 		}
 
-		// Extract the before last (value) and last (unit) fields
-		fields := strings.Split(line, " ")
-		value := fields[len(fields)-2]
-		totalMemory, err := strconv.ParseInt(value, 10, 64)
+		value, err := strconv.ParseInt(fields[1], 10, 64)
 		if err != nil {
-			return -1, fmt.Errorf("Invalid integer")
+			return nil, fmt.Errorf("Invalid integer")
 		}
 
-		// meminfo gives us kB
-		totalMemory *= 1024
+		values[strings.TrimSuffix(fields[0], ":")] = value
+	}
+
+	return values, scan.Err()
+}
+
+func anonPages() (int64, error) {
+	values, err := readMemInfo()
+	if err != nil {
+		return -1, err
+	}
+
+	// We only care about anonymous pages
+	totalMemory, ok := values["AnonPages"]
+	if !ok {
+		return 0, fmt.Errorf("Could not compute number of pages")
+	}
+
+	// meminfo gives us kB
+	totalMemory *= 1024
+
+	// Fetch the system page size
+	pageSize := (int64)(os.Getpagesize())
+
+	nPages := totalMemory / pageSize
+	return nPages, nil
+}
+
+// scanBasis describes the pages the pages_to_scan factors apply to.
+type scanBasis string
+
+const (
+	// scanBasisAnon sizes pages_to_scan from all the host anonymous
+	// pages.
+	scanBasisAnon scanBasis = "anon"
+
+	// scanBasisMergeable sizes pages_to_scan from the pages
+	// registered with KSM, i.e. the ones ksmd actually scans.
+	scanBasisMergeable scanBasis = "mergeable"
+)
+
+var ksmScanBasis = scanBasisAnon
+
+func parseScanBasis(s string) (scanBasis, error) {
+	for _, b := range []scanBasis{scanBasisAnon, scanBasisMergeable} {
+		if s == string(b) {
+			return b, nil
+		}
+	}
+
+	return "", fmt.Errorf("Invalid scan basis %q", s)
+}
+
+// mergeablePages returns the number of pages registered with KSM, as
+// accounted by ksmd during its last scan. pages_sharing does not
+// include the KSM pages themselves, hence pages_shared.
+func mergeablePages(root string) (int64, error) {
+	var nPages int64
+
+	for _, name := range []string{ksmPagesShared, ksmPagesSharing, ksmPagesUnshared, ksmPagesVolatile} {
+		value, err := readKSMCounter(root, name)
+		if err != nil {
+			return -1, err
+		}
+
+		nPages += value
+	}
+
+	return nPages, nil
+}
+
+// scanPages returns the number of pages the pages_to_scan factors
+// apply to, with root being the KSM sysfs root.
+func scanPages(root string) (int64, error) {
+	if ksmScanBasis == scanBasisMergeable {
+		nPages, err := mergeablePages(root)
+		if err != nil {
+			return -1, err
+		}
 
-		// Fetch the system page size
-		pageSize := (int64)(os.Getpagesize())
+		// ksmd only accounts for the mergeable pages it has
+		// scanned. Until it did, we have no better estimate
+		// than the anonymous pages.
+		if nPages > 0 {
+			return nPages, nil
+		}
 
-		nPages := totalMemory / pageSize
-		return nPages, nil
+		throttlerLog.Debug("No mergeable pages accounted yet, using anonymous pages")
 	}
 
-	return 0, fmt.Errorf("Could not compute number of pages")
+	return anonPages()
 }
 
-func (s ksmSetting) pagesToScan() (string, error) {
+func (s ksmSetting) pagesToScan(root string) (string, error) {
 	if s.pagesPerScanFactor == 0 {
 		return "", errors.New("Invalid KSM setting")
 	}
 
-	nPages, err := anonPages()
+	nPages, err := scanPages(root)
 	if err != nil {
 		return "", err
 	}
@@ -101,28 +187,62 @@ const (
 	ksmStandard   ksmMode = "standard"
 	ksmAggressive ksmMode = "aggressive"
 	ksmAuto       ksmMode = "auto"
+	ksmAdaptive   ksmMode = "adaptive"
+	ksmController ksmMode = "controller"
+	ksmBudget     ksmMode = "budget"
+
+	// ksmAdvisorPolicy is the auto mode, through the kernel scan advisor.
+	ksmAdvisorPolicy ksmMode = "advisor"
 )
 
 var ksmSettings = map[ksmMode]ksmSetting{
-	ksmOff:        {1000, 500, false}, // Turn KSM off
-	ksmSlow:       {500, 100, true},   // Every 100ms, we scan 1 page for every 500 pages available in the system
-	ksmStandard:   {100, 10, true},    // Every 10ms, we scan 1 page for every 100 pages available in the system
-	ksmAggressive: {10, 1, true},      // Every ms, we scan 1 page for every 10 pages available in the system
+	ksmOff:        {1000, 500, false, nil, false}, // Turn KSM off
+	ksmSlow:       {500, 100, true, nil, false},   // Every 100ms, we scan 1 page for every 500 pages available in the system
+	ksmStandard:   {100, 10, true, nil, false},    // Every 10ms, we scan 1 page for every 100 pages available in the system
+	ksmAggressive: {10, 1, true, nil, false},      // Every ms, we scan 1 page for every 10 pages available in the system
 }
 
+// ksmModes lists all the KSM modes.
+var ksmModes = []ksmMode{ksmInitial, ksmOff, ksmSlow, ksmStandard, ksmAggressive, ksmAuto, ksmAdaptive, ksmController, ksmBudget, ksmAdvisorPolicy}
+
 func (k ksmMode) String() string {
 	switch k {
 	case ksmOff:
 		return "off"
 	case ksmInitial:
 		return "initial"
+	case ksmSlow:
+		return "slow"
+	case ksmStandard:
+		return "standard"
+	case ksmAggressive:
+		return "aggressive"
 	case ksmAuto:
 		return "auto"
+	case ksmAdaptive:
+		return "adaptive"
+	case ksmController:
+		return "controller"
+	case ksmBudget:
+		return "budget"
+	case ksmAdvisorPolicy:
+		return "advisor"
 	}
 
 	return ""
 }
 
+// parseKSMMode converts a string into one of the KSM modes.
+func parseKSMMode(s string) (ksmMode, error) {
+	for _, mode := range ksmModes {
+		if mode.String() == s {
+			return mode, nil
+		}
+	}
+
+	return "", fmt.Errorf("Invalid KSM mode %q", s)
+}
+
 type sysfsAttribute struct {
 	path string
 	file *os.File
@@ -180,13 +300,46 @@ type ksm struct {
 	initialSleepInterval string
 	initialKSMRun        string
 
+	// knobs are the advanced KSM attributes the running kernel exports.
+	knobs map[string]*ksmKnob
+
+	// sandboxes maps the sandboxes that kicked us to their PIDs.
+	sandboxes map[string][]int
+
+	// policy is the mode we've been asked to run in.
+	policy ksmMode
+
 	currentKnob ksmMode
 
-	kickChannel chan bool
+	// knobChanged is signaled when currentKnob changes.
+	knobChanged chan struct{}
+
+	// events publishes what we do to the watchers.
+	events *eventBroker
+
+	// throttleDeadline is when the throttle down timer fires next.
+	// It is zero when no throttle down is pending.
+	throttleDeadline time.Time
+
+	// kickChannel signals the policy goroutine that pendingKick is
+	// set. pendingKick merges the kicks it did not take yet, for
+	// kickers never to wait for it.
+	kickChannel chan struct{}
+	pendingKick *ksmKick
+
+	// throttleStop is closed to stop the throttling goroutine,
+	// which closes throttleDone when returning.
+	throttleStop chan struct{}
+	throttleDone chan struct{}
 
 	throttling  bool
 	initialized bool
 
+	// modeLock serializes the mode changes, for the policy and the
+	// running policy goroutine to always match. Take it before the ksm
+	// lock.
+	modeLock sync.Mutex
+
 	sync.Mutex
 }
 
@@ -200,27 +353,52 @@ func (k *ksm) isAvailable() error {
 }
 
 // restoreSysFS is unlocked. You should take the ksm lock before calling it.
-func (k *ksm) restoreSysFS() error {
-	var err error
-
+func (k *ksm) restoreSysFS() (err error) {
 	if !k.initialized {
 		return errKSMUnavailable
 	}
 
-	if err = k.pagesToScan.write(k.initialPagesToScan); err != nil {
-		return err
+	defer func() {
+		if err != nil {
+			k.publishError(err)
+			return
+		}
+
+		k.publishValues(ksmEvent{
+			kind:    ksmEventRestore,
+			newMode: ksmInitial,
+		})
+	}()
+
+	// Busy knobs do not keep us from restoring the other settings.
+	// Unmerging is up to the mode that changed them.
+	knobsErr := k.restoreKnobs(ksmSettings[k.currentKnob].unmerge)
+
+	if !k.advising() {
+		if err = k.pagesToScan.write(k.initialPagesToScan); err != nil {
+			return err
+		}
 	}
 
 	if err = k.sleepInterval.write(k.initialSleepInterval); err != nil {
 		return err
 	}
 
-	return k.run.write(k.initialKSMRun)
+	if err = k.run.write(k.initialKSMRun); err != nil {
+		return err
+	}
+
+	return knobsErr
 }
 
 func (k *ksm) restore() error {
 	var err error
 
+	k.modeLock.Lock()
+	defer k.modeLock.Unlock()
+
+	k.stopThrottle()
+
 	k.Lock()
 	defer k.Unlock()
 
@@ -232,6 +410,8 @@ func (k *ksm) restore() error {
 		return err
 	}
 
+	removeState(ksmStateFile)
+
 	if err := k.run.close(); err != nil {
 		return err
 	}
@@ -244,11 +424,20 @@ func (k *ksm) restore() error {
 		return err
 	}
 
+	k.closeKnobs()
+
 	k.initialized = false
 	return nil
 }
 
 func (k *ksm) throttle() {
+	k.startPolicy(k.throttleLoop)
+}
+
+// startPolicy starts the loop goroutine driving the KSM settings, unless
+// one is already running. loop must return and close done once stop is
+// closed, and takes the kicks signaled on kickChannel meanwhile.
+func (k *ksm) startPolicy(loop func(stop, done chan struct{})) {
 	k.Lock()
 	defer k.Unlock()
 
@@ -257,101 +446,563 @@ func (k *ksm) throttle() {
 		return
 	}
 
-	k.currentKnob = ksmAggressive
+	// We're already throttling
+	if k.throttleStop != nil {
+		return
+	}
+
 	k.throttling = true
+	k.throttleStop = make(chan struct{})
+	k.throttleDone = make(chan struct{})
+	k.pendingKick = nil
 
-	go func() {
-		throttleTimer := time.NewTimer(ksmThrottleIntervals[k.currentKnob].interval)
-
-		for {
-			select {
-			case <-k.kickChannel:
-				// We got kicked, this means a new VM has been created.
-				// We will enter the aggressive setting until we throttle down.
-				_ = throttleTimer.Stop()
-				mode := ksmAggressive
-				if err := k.tune(ksmSettings[mode]); err != nil {
-					throttlerLog.WithError(err).WithField("ksm-mode", mode).Error("kick failed to tune")
-					continue
-				}
+	go loop(k.throttleStop, k.throttleDone)
+}
 
-				k.Lock()
-				k.currentKnob = ksmAggressive
-				k.Unlock()
+// throttleLoop waits for kicks and throttles KSM down, until stop is closed.
+func (k *ksm) throttleLoop(stop, done chan struct{}) {
+	defer close(done)
 
-				_ = throttleTimer.Reset(ksmAggressiveInterval)
-
-			case <-throttleTimer.C:
-				// Our throttling down timer kicked in.
-				// We will move down to the next knob and start the next time,
-				// if necessary.
-				var throttle = ksmThrottleIntervals[k.currentKnob]
-				if throttle.interval == 0 {
-					if throttle.nextKnob == ksmInitial {
-						k.Lock()
-						if err := k.restoreSysFS(); err != nil {
-							throttlerLog.WithError(err).Error("failed to restore sysfs")
-						}
-						k.Unlock()
-					}
-					continue
-				}
+	// The throttling down timer only runs after we get kicked.
+	throttleTimer := time.NewTimer(ksmAggressiveInterval)
+	_ = throttleTimer.Stop()
+
+	// lastTune is when we last re-tuned KSM for a kick.
+	var lastTune time.Time
+
+	for {
+		select {
+		case <-stop:
+			_ = throttleTimer.Stop()
+			return
+
+		case <-k.kickChannel:
+			kick, ok := k.takeKick()
+			if !ok {
+				continue
+			}
+
+			// We got kicked, this means a new VM has been created.
+			// We will enter the kick setting until we throttle down.
+			_ = throttleTimer.Stop()
 
-				currentKnob := k.currentKnob
-				nextKnob := ksmThrottleIntervals[currentKnob].nextKnob
-				interval := ksmThrottleIntervals[currentKnob].interval
-				if err := k.tune(ksmSettings[nextKnob]); err != nil {
-					throttlerLog.WithError(err).WithFields(logrus.Fields{
-						"current-ksm-mode": currentKnob,
-						"next-ksm-mode":    nextKnob,
-					}).Error("timer failed to tune")
-					continue
+			k.Lock()
+			currentKnob := k.currentKnob
+			deadline := k.throttleDeadline
+			k.Unlock()
+
+			// Kicks never throttle KSM down, they extend the
+			// current setting instead.
+			mode := kick.mode
+			if scanRate(currentKnob) > scanRate(mode) {
+				mode = currentKnob
+			}
+
+			// Kicks following a re-tune closely only extend the
+			// hold: ksmd keeps running through a re-tune, and the
+			// same settings would only be read back for nothing.
+			if mode == currentKnob && time.Since(lastTune) < ksmKickWindow {
+				throttlerMetrics.kicksCoalesced.inc()
+			} else if err := k.tuneMode(mode); err != nil {
+				throttlerLog.WithError(err).WithField("ksm-mode", mode).Error("kick failed to tune")
+				if !deadline.IsZero() {
+					_ = throttleTimer.Reset(time.Until(deadline))
 				}
+				continue
+			} else {
+				lastTune = time.Now()
+			}
+
+			now := time.Now()
+			newDeadline := now.Add(kick.hold)
+			if mode == currentKnob && deadline.After(newDeadline) {
+				newDeadline = deadline
+			}
+
+			k.Lock()
+			k.setKnob(mode)
+			k.throttleDeadline = newDeadline
+			k.Unlock()
+
+			_ = throttleTimer.Reset(newDeadline.Sub(now))
 
+		case <-throttleTimer.C:
+			// Our throttling down timer kicked in.
+			// We will move down to the next knob and start the next time,
+			// if necessary.
+			k.Lock()
+			currentKnob := k.currentKnob
+			k.Unlock()
+
+			// Kicks may move us to a mode that is not part of
+			// the throttling down chain.
+			throttle, ok := ksmThrottleIntervals[currentKnob]
+			if !ok {
+				throttle = ksmThrottleInterval{0, ksmInitial}
+			}
+
+			if throttle.interval == 0 {
 				k.Lock()
-				k.currentKnob = nextKnob
+				k.throttleDeadline = time.Time{}
+				if throttle.nextKnob == ksmInitial {
+					if err := k.restoreSysFS(); err != nil {
+						throttlerLog.WithError(err).Error("failed to restore sysfs")
+					} else {
+						k.setKnob(ksmInitial)
+					}
+				}
 				k.Unlock()
+				continue
+			}
 
-				_ = throttleTimer.Reset(interval)
+			nextKnob := throttle.nextKnob
+			interval := throttle.interval
+			if err := k.tuneMode(nextKnob); err != nil {
+				throttlerLog.WithError(err).WithFields(logrus.Fields{
+					"current-ksm-mode": currentKnob,
+					"next-ksm-mode":    nextKnob,
+				}).Error("timer failed to tune")
+				continue
 			}
+
+			k.Lock()
+			k.setKnob(nextKnob)
+			k.throttleDeadline = time.Now().Add(interval)
+			k.Unlock()
+
+			_ = throttleTimer.Reset(interval)
 		}
-	}()
+	}
+}
+
+// stopThrottle stops the throttling goroutine and waits for it to return.
+func (k *ksm) stopThrottle() {
+	k.Lock()
+	stop := k.throttleStop
+	done := k.throttleDone
+	k.throttling = false
+	k.throttleStop = nil
+	k.throttleDone = nil
+	k.throttleDeadline = time.Time{}
+	k.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	<-done
+}
+
+// setMode moves KSM to a new mode, starting or stopping the throttling
+// goroutine as needed.
+func (k *ksm) setMode(mode ksmMode) error {
+	k.modeLock.Lock()
+	defer k.modeLock.Unlock()
+
+	k.Lock()
+	initialized := k.initialized
+	k.Unlock()
+
+	if !initialized {
+		return errKSMUnavailable
+	}
+
+	knob := mode
+
+	switch mode {
+	case ksmAuto, ksmAdvisorPolicy, ksmController, ksmAdaptive, ksmBudget:
+		// In auto, advisor and controller modes, we go back to the initial
+		// settings until we get kicked. In adaptive mode, until
+		// memory pressure builds up. In budget mode, until the
+		// budget loop starts.
+		knob = ksmInitial
+
+	case ksmInitial:
+
+	default:
+		if _, ok := ksmSettings[mode]; !ok {
+			return fmt.Errorf("Invalid KSM mode %v", mode)
+		}
+	}
+
+	k.stopThrottle()
+
+	// The policy decides how we tune, e.g. through the scan advisor.
+	k.Lock()
+	k.policy = mode
+	k.Unlock()
+
+	if err := k.moveTo(knob); err != nil {
+		return err
+	}
+
+	switch mode {
+	case ksmAuto, ksmAdvisorPolicy:
+		k.throttle()
+	case ksmAdaptive:
+		k.startPolicy(k.adaptiveLoop)
+	case ksmController:
+		k.startPolicy(k.controllerLoop)
+	case ksmBudget:
+		k.startPolicy(k.budgetLoop)
+	}
+
+	return nil
+}
+
+// ksmValues holds the values of the KSM sysfs attributes we manage.
+type ksmValues struct {
+	run           string
+	pagesToScan   string
+	sleepInterval string
+
+	// knobs holds the advanced attributes the kernel exports.
+	knobs map[string]string
+}
+
+// ksmStatus is a snapshot of the throttler state.
+type ksmStatus struct {
+	policy     ksmMode
+	knob       ksmMode
+	throttling bool
+	remaining  time.Duration
+	current    ksmValues
+	initial    ksmValues
+	sandboxes  int
+}
+
+// currentValues is unlocked. You should take the ksm lock before calling it.
+func (k *ksm) currentValues() (ksmValues, error) {
+	var v ksmValues
+	var err error
+
+	if v.run, err = k.run.read(); err != nil {
+		return v, err
+	}
+
+	if v.pagesToScan, err = k.pagesToScan.read(); err != nil {
+		return v, err
+	}
+
+	if v.sleepInterval, err = k.sleepInterval.read(); err != nil {
+		return v, err
+	}
+
+	if v.knobs, err = k.currentKnobs(); err != nil {
+		return v, err
+	}
+
+	return v.trim(), nil
 }
 
-func (k *ksm) tune(s ksmSetting) error {
+// trim strips the trailing newline sysfs appends to attribute values.
+func (v ksmValues) trim() ksmValues {
+	return ksmValues{
+		run:           strings.TrimSpace(v.run),
+		pagesToScan:   strings.TrimSpace(v.pagesToScan),
+		sleepInterval: strings.TrimSpace(v.sleepInterval),
+		knobs:         v.knobs,
+	}
+}
+
+func (k *ksm) status() (ksmStatus, error) {
+	var err error
+	var s ksmStatus
+
 	k.Lock()
 	defer k.Unlock()
 
+	if !k.initialized {
+		return s, errKSMUnavailable
+	}
+
+	s.policy = k.policy
+	s.knob = k.currentKnob
+	s.throttling = k.throttling
+	s.sandboxes = len(k.sandboxes)
+
+	if !k.throttleDeadline.IsZero() {
+		if s.remaining = time.Until(k.throttleDeadline); s.remaining < 0 {
+			s.remaining = 0
+		}
+	}
+
+	s.current, err = k.currentValues()
+	if err != nil {
+		return s, err
+	}
+
+	s.initial = ksmValues{
+		run:           k.initialKSMRun,
+		pagesToScan:   k.initialPagesToScan,
+		sleepInterval: k.initialSleepInterval,
+		knobs:         k.initialKnobs(),
+	}.trim()
+
+	return s, nil
+}
+
+// setKnob is unlocked. You should take the ksm lock before calling it.
+func (k *ksm) setKnob(mode ksmMode) {
+	if k.currentKnob == mode {
+		return
+	}
+
+	throttlerMetrics.transitions.inc()
+	k.publishValues(ksmEvent{
+		kind:    ksmEventTransition,
+		oldMode: k.currentKnob,
+		newMode: mode,
+	})
+	k.currentKnob = mode
+
+	select {
+	case k.knobChanged <- struct{}{}:
+	default:
+	}
+}
+
+func (k *ksm) tune(s ksmSetting) (err error) {
+	k.Lock()
+	defer k.Unlock()
+
+	defer func() {
+		if err != nil {
+			throttlerMetrics.tuneFailures.inc()
+			k.publishError(err)
+		}
+	}()
+
 	if !k.initialized {
 		return errKSMUnavailable
 	}
 
 	if !s.run {
-		return k.run.write(ksmStop)
+		return k.stopValues(k.settingKnobs(s.knobs), s.unmerge)
+	}
+
+	newPagesToScan, err := s.pagesToScan(k.root)
+	if err != nil {
+		return err
+	}
+
+	return k.writeValues(newPagesToScan, fmt.Sprintf("%v", s.scanIntervalMS), k.settingKnobs(s.knobs), s.unmerge)
+}
+
+// tuneValues runs KSM with explicit pages_to_scan and sleep_millisecs
+// values, and a setting advanced attributes values.
+func (k *ksm) tuneValues(pagesToScan, sleepInterval string, s ksmSetting) (err error) {
+	k.Lock()
+	defer k.Unlock()
+
+	defer func() {
+		if err != nil {
+			throttlerMetrics.tuneFailures.inc()
+			k.publishError(err)
+		}
+	}()
+
+	if !k.initialized {
+		return errKSMUnavailable
 	}
 
-	newPagesToScan, err := s.pagesToScan()
+	return k.writeValues(pagesToScan, sleepInterval, k.settingKnobs(s.knobs), s.unmerge)
+}
+
+// writeValues is unlocked. You should take the ksm lock before calling it.
+// It runs KSM with the given values, rolling all of them back if one
+// can not be written.
+func (k *ksm) writeValues(pagesToScan, sleepInterval string, knobs map[string]string, unmerge bool) (err error) {
+	t, err := k.begin(unmerge)
 	if err != nil {
 		return err
 	}
 
-	if err = k.run.write(ksmStop); err != nil {
+	defer func() {
+		if err != nil {
+			t.rollback()
+		}
+	}()
+
+	if err = t.setKnobs(knobs); err != nil {
 		return err
 	}
 
-	if err = k.pagesToScan.write(newPagesToScan); err != nil {
+	// The kernel scan advisor owns pages_to_scan
+	if !k.advising() {
+		if err = t.set(ksmPagesToScan, pagesToScan); err != nil {
+			return err
+		}
+	}
+
+	if err = t.set(ksmSleepMillisec, sleepInterval); err != nil {
 		return err
 	}
 
-	if err = k.sleepInterval.write(fmt.Sprintf("%v", s.scanIntervalMS)); err != nil {
+	return t.set(ksmRunFile, ksmStart)
+}
+
+// stopValues is unlocked. You should take the ksm lock before calling it.
+// It stops KSM and writes the knobs values, rolling all of them back if
+// one can not be written.
+func (k *ksm) stopValues(knobs map[string]string, unmerge bool) (err error) {
+	t, err := k.begin(unmerge)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			t.rollback()
+		}
+	}()
+
+	if err = t.set(ksmRunFile, ksmStop); err != nil {
+		return err
+	}
+
+	return t.setKnobs(knobs)
+}
+
+// tuneMode tunes KSM to a mode settings, through the kernel scan
+// advisor in advisor mode.
+func (k *ksm) tuneMode(mode ksmMode) error {
+	k.Lock()
+	setting := ksmSettings[mode]
+	if k.policy == ksmAdvisorPolicy {
+		setting = k.advisorSetting(mode, setting)
+	}
+	k.Unlock()
+
+	return k.tune(setting)
+}
+
+// moveTo tunes KSM to a mode settings, or restores the initial
+// settings for ksmInitial.
+func (k *ksm) moveTo(mode ksmMode) error {
+	if mode == ksmInitial {
+		k.Lock()
+		defer k.Unlock()
+
+		if err := k.restoreSysFS(); err != nil {
+			return err
+		}
+
+		k.setKnob(ksmInitial)
+		return nil
+	}
+
+	if err := k.tuneMode(mode); err != nil {
 		return err
 	}
 
-	return k.run.write(ksmStart)
+	k.Lock()
+	k.setKnob(mode)
+	k.Unlock()
+
+	return nil
 }
 
-// kick gets us back to the aggressive setting
+// ksmKick describes a kick: The KSM mode to move to, and how long to
+// hold it before throttling down.
+type ksmKick struct {
+	mode ksmMode
+	hold time.Duration
+
+	// reason tells why we got kicked, for logging purpose.
+	reason string
+
+	// sandbox is the ID of the sandbox that kicked us, if any.
+	sandbox string
+}
+
+// newKSMKick validates a kick request. An empty mode stands for the
+// kick mode, and a zero hold for the time the throttling down chain
+// holds the mode.
+func newKSMKick(mode string, hold time.Duration, reason string) (ksmKick, error) {
+	kick := ksmKick{
+		mode:   ksmKickMode,
+		hold:   hold,
+		reason: reason,
+	}
+
+	if mode != "" {
+		m, err := parseKSMMode(mode)
+		if err != nil {
+			return kick, err
+		}
+
+		if s, ok := ksmSettings[m]; !ok || !s.run {
+			return kick, fmt.Errorf("Can not kick KSM to the %s mode", m)
+		}
+
+		kick.mode = m
+	}
+
+	if hold < 0 {
+		return kick, fmt.Errorf("Invalid kick hold duration %v", hold)
+	}
+
+	if hold == 0 {
+		kick.hold = modeHold(kick.mode)
+	}
+
+	return kick, nil
+}
+
+// modeHold returns how long the throttling down chain holds a mode.
+func modeHold(mode ksmMode) time.Duration {
+	if mode == ksmKickMode {
+		return ksmAggressiveInterval
+	}
+
+	for _, t := range ksmThrottleIntervals {
+		if t.nextKnob == mode && t.interval > 0 {
+			return t.interval
+		}
+	}
+
+	return ksmAggressiveInterval
+}
+
+// scanRate tells how aggressive a KSM mode is, as the share of the
+// pages scanned per second. The initial settings rank last.
+func scanRate(mode ksmMode) float64 {
+	s, ok := ksmSettings[mode]
+	if !ok || !s.run || s.pagesPerScanFactor <= 0 {
+		return 0
+	}
+
+	interval := float64(s.scanIntervalMS)
+	if interval < 1 {
+		interval = 1
+	}
+
+	return 1000 / (float64(s.pagesPerScanFactor) * interval)
+}
+
+// kick moves KSM to the kick mode for ksmAggressiveInterval.
 func (k *ksm) kick() {
+	k.kickWith(ksmKick{
+		mode: ksmKickMode,
+		hold: ksmAggressiveInterval,
+	})
+}
+
+func (k *ksm) kickWith(kick ksmKick) {
+	throttlerMetrics.kicks.inc()
+	k.events.publish(ksmEvent{
+		kind: ksmEventKick,
+		kick: kick,
+	})
+
+	throttlerLog.WithFields(logrus.Fields{
+		"ksm-mode": kick.mode,
+		"hold":     kick.hold,
+		"reason":   kick.reason,
+	}).Debug("Kicked")
+
 	k.Lock()
 
 	if !k.initialized {
@@ -366,66 +1017,71 @@ func (k *ksm) kick() {
 		return
 	}
 
+	// Kicks the policy goroutine did not take yet merge together
+	if k.pendingKick != nil {
+		kick = mergeKicks(*k.pendingKick, kick)
+		throttlerMetrics.kicksCoalesced.inc()
+	}
+	k.pendingKick = &kick
 	k.Unlock()
-	k.kickChannel <- true
+
+	select {
+	case k.kickChannel <- struct{}{}:
+	default:
+		// The policy goroutine has yet to take the pending kick
+	}
 }
 
-func startKSM(root string, mode ksmMode) (*ksm, error) {
-	k, err := newKSM(root)
-	if err != nil {
-		return k, err
+// takeKick returns the pending kick, if any, for the policy goroutine to
+// handle it.
+func (k *ksm) takeKick() (ksmKick, bool) {
+	k.Lock()
+	defer k.Unlock()
+
+	if k.pendingKick == nil {
+		return ksmKick{}, false
 	}
 
-	// We just no-op if going for initial settings
-	if mode != ksmInitial {
-		// We want to catch termination to restore the initial sysfs values
-		c := make(chan os.Signal, 2)
-		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	kick := *k.pendingKick
+	k.pendingKick = nil
 
-		for _, sig := range ksig.HandledSignals() {
-			signal.Notify(c, sig)
-		}
+	return kick, true
+}
 
-		go func() {
-			for {
-				// Block waiting for a signal
-				sig := <-c
+// mergeKicks returns a kick asking for the most aggressive mode and the
+// longest hold of both kicks.
+func mergeKicks(a, b ksmKick) ksmKick {
+	merged := b
 
-				if sig == syscall.SIGTERM {
-					_ = k.restore()
-					os.Exit(0)
-				}
+	if scanRate(a.mode) > scanRate(b.mode) {
+		merged.mode = a.mode
+	}
 
-				nativeSignal, ok := sig.(syscall.Signal)
-				if !ok {
-					err := errors.New("unknown signal")
-					throttlerLog.WithError(err).WithField("signal", sig.String()).Error()
-					continue
-				}
+	if a.hold > b.hold {
+		merged.hold = a.hold
+	}
 
-				if ksig.FatalSignal(nativeSignal) {
-					throttlerLog.WithField("signal", sig).Error("received fatal signal")
-					ksig.Die()
-				} else if ksig.NonFatalSignal(nativeSignal) {
-					if debug {
-						throttlerLog.WithField("signal", sig).Debug("handling signal")
-						ksig.Backtrace()
-					}
-				}
-			}
-		}()
+	if merged.reason == "" {
+		merged.reason = a.reason
+	}
 
-		if mode == ksmAuto {
-			k.throttle()
-		} else {
-			setting, ok := ksmSettings[mode]
-			if !ok {
-				return k, fmt.Errorf("Invalid KSM mode %v", mode)
-			}
+	if merged.sandbox == "" {
+		merged.sandbox = a.sandbox
+	}
 
-			if err := k.tune(setting); err != nil {
-				return k, err
-			}
+	return merged
+}
+
+func startKSM(root string, mode ksmMode) (*ksm, error) {
+	k, err := newKSM(root)
+	if err != nil {
+		return k, err
+	}
+
+	// We just no-op if going for initial settings
+	if mode != ksmInitial {
+		if err := k.setMode(mode); err != nil {
+			return k, err
 		}
 	}
 
@@ -438,6 +1094,11 @@ func newKSM(root string) (*ksm, error) {
 
 	k.initialized = false
 	k.throttling = false
+	k.currentKnob = ksmInitial
+	k.policy = ksmInitial
+	k.knobChanged = make(chan struct{}, 1)
+	k.events = newEventBroker()
+	k.sandboxes = make(map[string][]int)
 	k.root = root
 
 	if root == "" {
@@ -495,8 +1156,16 @@ func newKSM(root string) (*ksm, error) {
 		return nil, err
 	}
 
+	if err = k.openKnobs(); err != nil {
+		return nil, err
+	}
+
+	if ksmStateFile != "" {
+		k.loadState(ksmStateFile)
+	}
+
 	k.initialized = true
-	k.kickChannel = make(chan bool)
+	k.kickChannel = make(chan struct{}, 1)
 
 	return &k, nil
 }
@@ -11,6 +11,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -150,17 +151,69 @@ func TestKSMPagesToScan(t *testing.T) {
 	assert.Nil(t, err)
 	expectedPagesToScan := fmt.Sprintf("%v", anonPages/setting.pagesPerScanFactor)
 
-	pagesToScan, err := setting.pagesToScan()
+	pagesToScan, err := setting.pagesToScan(defaultKSMRoot)
 	assert.Nil(t, err)
 	assert.Equal(t, pagesToScan, expectedPagesToScan, "")
 }
 
+func TestKSMPagesToScanMergeable(t *testing.T) {
+	savedBasis := ksmScanBasis
+	ksmScanBasis = scanBasisMergeable
+	defer func() {
+		ksmScanBasis = savedBasis
+	}()
+
+	setting := ksmSettings[ksmAggressive]
+
+	anonPages, err := anonPages()
+	assert.Nil(t, err)
+
+	// Nothing accounted yet, we fall back to the anonymous pages
+	counters := map[string]string{
+		ksmPagesShared:   "0",
+		ksmPagesSharing:  "0",
+		ksmPagesUnshared: "0",
+		ksmPagesVolatile: "0",
+	}
+
+	err = writeKSMCounters(counters)
+	defer removeKSMCounters(counters)
+	assert.Nil(t, err)
+
+	pagesToScan, err := setting.pagesToScan(defaultKSMRoot)
+	assert.Nil(t, err)
+	assert.Equal(t, fmt.Sprintf("%v", anonPages/setting.pagesPerScanFactor), pagesToScan)
+
+	counters = map[string]string{
+		ksmPagesShared:   "100",
+		ksmPagesSharing:  "400",
+		ksmPagesUnshared: "450",
+		ksmPagesVolatile: "50",
+	}
+
+	err = writeKSMCounters(counters)
+	assert.Nil(t, err)
+
+	pagesToScan, err = setting.pagesToScan(defaultKSMRoot)
+	assert.Nil(t, err)
+	assert.Equal(t, "100", pagesToScan)
+}
+
+func TestParseScanBasis(t *testing.T) {
+	basis, err := parseScanBasis("mergeable")
+	assert.Nil(t, err)
+	assert.Equal(t, scanBasisMergeable, basis)
+
+	_, err = parseScanBasis("rss")
+	assert.NotNil(t, err)
+}
+
 func TestKSMPagesToScanInvalidSetting(t *testing.T) {
 	setting := ksmSetting{
 		pagesPerScanFactor: 0,
 	}
 
-	_, err := setting.pagesToScan()
+	_, err := setting.pagesToScan(defaultKSMRoot)
 	assert.NotNil(t, err)
 }
 
@@ -366,6 +419,27 @@ func TestKSMModeString(t *testing.T) {
 	k = ksmAuto
 	s = string(k)
 	assert.Equal(t, string(k), s)
+
+	for _, k = range ksmModes {
+		assert.Equal(t, string(k), k.String())
+	}
+
+	k = "foo"
+	assert.Equal(t, "", k.String())
+}
+
+func TestKSMModeParse(t *testing.T) {
+	for _, mode := range ksmModes {
+		k, err := parseKSMMode(mode.String())
+		assert.Nil(t, err)
+		assert.Equal(t, mode, k)
+	}
+
+	_, err := parseKSMMode("")
+	assert.NotNil(t, err)
+
+	_, err = parseKSMMode("foo")
+	assert.NotNil(t, err)
 }
 
 func boolString(b bool) string {
@@ -450,6 +524,121 @@ func TestKSMThrottle(t *testing.T) {
 	testThrottle(k, t)
 }
 
+func TestNewKSMKick(t *testing.T) {
+	kick, err := newKSMKick("", 0, "")
+	assert.Nil(t, err)
+	assert.Equal(t, ksmKickMode, kick.mode)
+	assert.Equal(t, ksmAggressiveInterval, kick.hold)
+
+	kick, err = newKSMKick("standard", 0, "large VM")
+	assert.Nil(t, err)
+	assert.Equal(t, ksmStandard, kick.mode)
+	assert.Equal(t, ksmStandardInterval, kick.hold)
+	assert.Equal(t, "large VM", kick.reason)
+
+	kick, err = newKSMKick("slow", time.Hour, "")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, kick.hold)
+
+	for _, mode := range []string{"off", "auto", "initial", "turbo"} {
+		_, err = newKSMKick(mode, 0, "")
+		assert.NotNil(t, err, "%s kick should be rejected", mode)
+	}
+
+	_, err = newKSMKick("standard", -time.Second, "")
+	assert.NotNil(t, err)
+}
+
+func TestKSMKickMode(t *testing.T) {
+	k := initKSM(defaultKSMRoot, t)
+	defer k.restore()
+
+	k.throttle()
+
+	k.kickWith(ksmKick{mode: ksmStandard, hold: time.Hour})
+	assert.True(t, waitForKnob(k, ksmStandard, time.Second))
+
+	s, err := k.status()
+	assert.Nil(t, err)
+	assert.True(t, s.remaining > 59*time.Minute)
+
+	k.kickWith(ksmKick{mode: ksmAggressive, hold: 200 * time.Millisecond})
+	assert.True(t, waitForKnob(k, ksmAggressive, time.Second))
+
+	// Kicks never throttle down: We stay aggressive, and then
+	// follow the throttling down chain.
+	k.kickWith(ksmKick{mode: ksmSlow, hold: 200 * time.Millisecond})
+	assert.True(t, waitForKnob(k, ksmStandard, time.Second))
+
+	s, err = k.status()
+	assert.Nil(t, err)
+	assert.True(t, s.remaining > time.Minute)
+}
+
+func TestKSMKickCoalescing(t *testing.T) {
+	k := initKSM(defaultKSMRoot, t)
+	defer k.restore()
+
+	k.throttle()
+
+	k.kickWith(ksmKick{mode: ksmStandard, hold: time.Hour})
+	assert.True(t, waitForKnob(k, ksmStandard, time.Second))
+
+	sleepPath := filepath.Join(defaultKSMRoot, ksmSleepMillisec)
+	err := ioutil.WriteFile(sleepPath, []byte("1234"), 0644)
+	assert.Nil(t, err)
+
+	// Kicks within the window extend the hold without re-tuning
+	coalesced := throttlerMetrics.kicksCoalesced.get()
+	k.kickWith(ksmKick{mode: ksmStandard, hold: 2 * time.Hour})
+
+	deadline := time.Now().Add(time.Second)
+	for throttlerMetrics.kicksCoalesced.get() == coalesced && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, throttlerMetrics.kicksCoalesced.get() > coalesced)
+
+	s, err := k.status()
+	assert.Nil(t, err)
+	assert.True(t, s.remaining > time.Hour+59*time.Minute)
+
+	sleep, err := ioutil.ReadFile(sleepPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "1234", string(sleep))
+}
+
+func TestMergeKicks(t *testing.T) {
+	a := ksmKick{mode: ksmAggressive, hold: time.Minute, reason: "VM started", sandbox: "a"}
+	b := ksmKick{mode: ksmSlow, hold: time.Hour}
+
+	merged := mergeKicks(a, b)
+	assert.Equal(t, ksmAggressive, merged.mode)
+	assert.Equal(t, time.Hour, merged.hold)
+	assert.Equal(t, "VM started", merged.reason)
+	assert.Equal(t, "a", merged.sandbox)
+
+	b.sandbox = "b"
+	merged = mergeKicks(a, b)
+	assert.Equal(t, "b", merged.sandbox)
+}
+
+func TestKSMKickNoWait(t *testing.T) {
+	k := initKSM(defaultKSMRoot, t)
+
+	// Nobody takes the kicks: They merge instead of blocking
+	k.throttling = true
+	k.kickWith(ksmKick{mode: ksmSlow, hold: time.Minute})
+	k.kickWith(ksmKick{mode: ksmAggressive, hold: time.Second})
+
+	kick, ok := k.takeKick()
+	assert.True(t, ok)
+	assert.Equal(t, ksmAggressive, kick.mode)
+	assert.Equal(t, time.Minute, kick.hold)
+
+	_, ok = k.takeKick()
+	assert.False(t, ok)
+}
+
 func TestKSMStartInitialMode(t *testing.T) {
 	var err error
 
@@ -531,3 +720,132 @@ func TestKSMStartInvalidMode(t *testing.T) {
 	assert.NotNil(t, k)
 	assert.NotNil(t, err)
 }
+
+func TestKSMStatus(t *testing.T) {
+	k := initKSM(defaultKSMRoot, t)
+
+	s, err := k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmInitial, s.knob)
+	assert.False(t, s.throttling)
+	assert.Equal(t, time.Duration(0), s.remaining)
+	assert.Equal(t, s.initial, s.current)
+
+	err = k.tune(ksmSettings[ksmStandard])
+	assert.Nil(t, err)
+
+	s, err = k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmStart, s.current.run)
+	assert.Equal(t, fmt.Sprintf("%d", ksmSettings[ksmStandard].scanIntervalMS), s.current.sleepInterval)
+
+	k.restore()
+
+	_, err = k.status()
+	assert.Equal(t, errKSMUnavailable, err)
+}
+
+func TestKSMSetModeConcurrent(t *testing.T) {
+	k, err := startKSM(defaultKSMRoot, ksmAuto)
+	assert.Nil(t, err)
+	defer k.restore()
+
+	modes := []ksmMode{ksmAuto, ksmOff, ksmSlow, ksmInitial, ksmAuto, ksmAggressive}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		for _, mode := range modes {
+			wg.Add(1)
+			go func(mode ksmMode) {
+				defer wg.Done()
+
+				assert.Nil(t, k.setMode(mode))
+				k.kick()
+				_ = k.rebaseline()
+			}(mode)
+		}
+	}
+
+	wg.Wait()
+
+	// The policy matches the running goroutine
+	k.Lock()
+	policy := k.policy
+	running := k.throttleStop != nil
+	k.Unlock()
+
+	assert.Equal(t, policy == ksmAuto, running, "policy %s", policy)
+
+	err = k.setMode(ksmAuto)
+	assert.Nil(t, err)
+
+	s, err := k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmAuto, s.policy)
+	assert.True(t, s.throttling)
+}
+
+func TestKSMSetMode(t *testing.T) {
+	runSysFs := sysfsAttribute{
+		path: filepath.Join(defaultKSMRoot, ksmRunFile),
+	}
+
+	err := runSysFs.open()
+	defer runSysFs.close()
+	assert.Nil(t, err)
+
+	k, err := startKSM(defaultKSMRoot, ksmAuto)
+	assert.Nil(t, err)
+	defer k.restore()
+
+	initialRun := k.initialKSMRun
+
+	s, err := k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmAuto, s.policy)
+	assert.True(t, s.throttling)
+
+	// Moving to a fixed mode stops throttling
+	err = k.setMode(ksmOff)
+	assert.Nil(t, err)
+
+	s, err = k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmOff, s.policy)
+	assert.Equal(t, ksmOff, s.knob)
+	assert.False(t, s.throttling)
+	assert.Nil(t, k.throttleStop)
+
+	run, err := runSysFs.read()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmStop, run)
+
+	// Kicks are ignored when not throttling
+	k.kick()
+
+	// Back to auto, with the initial settings and kicks enabled
+	err = k.setMode(ksmAuto)
+	assert.Nil(t, err)
+
+	run, err = runSysFs.read()
+	assert.Nil(t, err)
+	assert.Equal(t, initialRun, run)
+
+	s, err = k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmAuto, s.policy)
+	assert.Equal(t, ksmInitial, s.knob)
+	assert.True(t, s.throttling)
+
+	k.kick()
+	time.Sleep(100 * time.Millisecond)
+
+	s, err = k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, ksmKickMode, s.knob)
+	assert.NotEqual(t, time.Duration(0), s.remaining)
+
+	err = k.setMode("foo")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,52 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/kata-containers/ksm-throttler/pkg/ksmctl"
+	"golang.org/x/net/context"
+)
+
+// DefaultURI is populated at link time - see the Makefile
+var DefaultURI string
+
+// version is the ksmctl version. This variable is populated at build time.
+var version = "unknown"
+
+const defaultgRPCSocket = "/var/run/kata-ksm-throttler/ksm.sock"
+
+func main() {
+	// Invoking "go build" without any linker option will not
+	// populate DefaultURI, so fallback to a reasonable path.
+	if DefaultURI == "" {
+		DefaultURI = defaultgRPCSocket
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Let watch return cleanly when interrupted
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	code := ksmctl.Run(ctx, ksmctl.Config{
+		URI:     DefaultURI,
+		Version: version,
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+	}, os.Args[1:])
+
+	cancel()
+	os.Exit(code)
+}
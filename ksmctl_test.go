@@ -0,0 +1,184 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kata-containers/ksm-throttler/pkg/ksmctl"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+// runKSMCtl runs ksmctl against the uri socket and returns its exit
+// code and output.
+func runKSMCtl(ctx context.Context, uri string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+
+	code := ksmctl.Run(ctx, ksmctl.Config{
+		URI:     uri,
+		Version: "test",
+		Stdout:  &stdout,
+		Stderr:  &stderr,
+	}, args)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestKSMCtl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ksmthrottler-ksmctl")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	uri := filepath.Join(dir, "ksm.sock")
+	ctx := context.Background()
+
+	// The throttler is not running yet
+	code, _, stderr := runKSMCtl(ctx, uri, "-timeout", "500ms", "status")
+	assert.Equal(t, ksmctl.ExitNotRunning, code)
+	assert.Contains(t, stderr, "not running")
+
+	k, err := startKSM(defaultKSMRoot, ksmAuto)
+	assert.Nil(t, err)
+
+	throttler := &ksmThrottler{
+		k:   k,
+		uri: uri,
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	runErr := make(chan error)
+
+	go func() {
+		runErr <- throttler.run(runCtx)
+	}()
+
+	defer func() {
+		cancel()
+
+		select {
+		case err := <-runErr:
+			assert.Nil(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("KSM throttler did not stop")
+		}
+	}()
+
+	// Wait for the socket, not to wait for gRPC to reconnect
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(uri); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	code, stdout, _ := runKSMCtl(ctx, uri, "version")
+	assert.Equal(t, ksmctl.ExitOK, code)
+	assert.Equal(t, "ksmctl version test\n", stdout)
+
+	code, stdout, _ = runKSMCtl(ctx, uri, "status")
+	assert.Equal(t, ksmctl.ExitOK, code)
+	assert.Contains(t, stdout, "Mode:       initial")
+	assert.Contains(t, stdout, "Policy:     auto")
+
+	counters := map[string]string{
+		ksmPagesShared:   "100",
+		ksmPagesSharing:  "400",
+		ksmPagesUnshared: "50",
+		ksmPagesVolatile: "10",
+		ksmFullScans:     "3",
+	}
+	assert.Nil(t, writeKSMCounters(counters))
+	defer removeKSMCounters(counters)
+
+	code, stdout, _ = runKSMCtl(ctx, uri, "-json", "stats")
+	assert.Equal(t, ksmctl.ExitOK, code)
+
+	var stats map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &stats))
+	assert.Equal(t, float64(400), stats["pages_sharing"])
+
+	// Watch the events of a kick
+	watchCtx, watchCancel := context.WithCancel(ctx)
+	defer watchCancel()
+
+	watched := make(chan string, 1)
+	go func() {
+		_, stdout, _ := runKSMCtl(watchCtx, uri, "-json", "watch", "-n", "2")
+		watched <- stdout
+	}()
+
+	for i := 0; i < 500 && k.events.count() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	code, _, _ = runKSMCtl(ctx, uri, "kick", "-mode", "standard", "-reason", "test")
+	assert.Equal(t, ksmctl.ExitOK, code)
+
+	select {
+	case stdout = <-watched:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ksmctl watch did not return")
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	assert.Equal(t, 2, len(lines))
+
+	var event map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &event))
+	assert.Equal(t, "kick", event["type"])
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &event))
+	assert.Equal(t, "transition", event["type"])
+	assert.Equal(t, "standard", event["new_mode"])
+
+	code, stdout, _ = runKSMCtl(ctx, uri, "-json", "set-mode", "slow")
+	assert.Equal(t, ksmctl.ExitOK, code)
+
+	var status map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &status))
+	assert.Equal(t, "slow", status["mode"])
+
+	// We do not re-baseline our own settings
+	code, _, stderr = runKSMCtl(ctx, uri, "rebaseline")
+	assert.Equal(t, ksmctl.ExitFailure, code)
+	assert.Contains(t, stderr, "initial settings")
+
+	code, stdout, _ = runKSMCtl(ctx, uri, "restore")
+	assert.Equal(t, ksmctl.ExitOK, code)
+	assert.Contains(t, stdout, "Mode:       initial")
+	assert.Contains(t, stdout, "Throttling: false")
+
+	code, stdout, _ = runKSMCtl(ctx, uri, "rebaseline")
+	assert.Equal(t, ksmctl.ExitOK, code)
+	assert.Contains(t, stdout, "Mode:       initial")
+
+	// Failures
+	code, _, _ = runKSMCtl(ctx, uri, "set-mode", "turbo")
+	assert.Equal(t, ksmctl.ExitFailure, code)
+
+	code, _, _ = runKSMCtl(ctx, uri, "kick", "-hold", "-1s")
+	assert.Equal(t, ksmctl.ExitFailure, code)
+
+	code, _, _ = runKSMCtl(ctx, uri, "kick", "-sandbox", "sandbox", "pid")
+	assert.Equal(t, ksmctl.ExitUsage, code)
+
+	code, _, _ = runKSMCtl(ctx, uri, "set-mode")
+	assert.Equal(t, ksmctl.ExitUsage, code)
+
+	code, _, _ = runKSMCtl(ctx, uri, "unknown")
+	assert.Equal(t, ksmctl.ExitUsage, code)
+
+	code, _, _ = runKSMCtl(ctx, uri)
+	assert.Equal(t, ksmctl.ExitUsage, code)
+}
@@ -0,0 +1,171 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	metricsNamespace = "ksm_throttler"
+	metricsPath      = "/metrics"
+)
+
+type metricsCounter struct {
+	value uint64
+}
+
+func (c *metricsCounter) inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+func (c *metricsCounter) get() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+// throttlerMetrics holds the throttler event counters.
+var throttlerMetrics struct {
+	kicks          metricsCounter
+	kicksCoalesced metricsCounter
+	kicksLimited   metricsCounter
+	transitions    metricsCounter
+	tuneFailures   metricsCounter
+}
+
+// metricsModes are the KSM modes exported through the mode gauge.
+var metricsModes = []ksmMode{ksmInitial, ksmOff, ksmSlow, ksmStandard, ksmAggressive, ksmBudget}
+
+// metricsWriter writes metrics in the Prometheus text exposition format.
+type metricsWriter struct {
+	w *bufio.Writer
+}
+
+func (m *metricsWriter) header(name, kind, help string) {
+	fmt.Fprintf(m.w, "# HELP %s_%s %s\n", metricsNamespace, name, help)
+	fmt.Fprintf(m.w, "# TYPE %s_%s %s\n", metricsNamespace, name, kind)
+}
+
+func (m *metricsWriter) sample(name, labels string, value interface{}) {
+	fmt.Fprintf(m.w, "%s_%s%s %v\n", metricsNamespace, name, labels, value)
+}
+
+func (m *metricsWriter) metric(name, kind, help string, value interface{}) {
+	m.header(name, kind, help)
+	m.sample(name, "", value)
+}
+
+// sysfsGauge exports a sysfs value, skipping it if it is not a number.
+func (m *metricsWriter) sysfsGauge(name, help, value string) {
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		throttlerLog.WithError(err).WithField("metric", name).Debug("invalid sysfs value")
+		return
+	}
+
+	m.metric(name, "gauge", help, v)
+}
+
+func writeMetrics(w io.Writer, k *ksm) error {
+	m := &metricsWriter{w: bufio.NewWriter(w)}
+
+	m.metric("kicks_total", "counter", "Number of kicks received.", throttlerMetrics.kicks.get())
+	m.metric("kicks_coalesced_total", "counter", "Number of kicks that did not re-tune KSM.", throttlerMetrics.kicksCoalesced.get())
+	m.metric("kicks_rate_limited_total", "counter", "Number of kicks rejected by the rate limit.", throttlerMetrics.kicksLimited.get())
+	m.metric("mode_transitions_total", "counter", "Number of KSM mode transitions.", throttlerMetrics.transitions.get())
+	m.metric("tune_failures_total", "counter", "Number of failures to tune KSM.", throttlerMetrics.tuneFailures.get())
+
+	if k == nil {
+		return m.w.Flush()
+	}
+
+	status, err := k.status()
+	if err != nil {
+		throttlerLog.WithError(err).Debug("could not get KSM status")
+		return m.w.Flush()
+	}
+
+	m.header("mode", "gauge", "Current KSM mode.")
+	for _, mode := range metricsModes {
+		value := 0
+		if status.knob == mode {
+			value = 1
+		}
+		m.sample("mode", fmt.Sprintf("{mode=%q}", string(mode)), value)
+	}
+
+	m.sysfsGauge("run", "Current value of the KSM run attribute.", status.current.run)
+	m.sysfsGauge("pages_to_scan", "Current value of the KSM pages_to_scan attribute.", status.current.pagesToScan)
+	m.sysfsGauge("sleep_millisecs", "Current value of the KSM sleep_millisecs attribute.", status.current.sleepInterval)
+	m.metric("sandboxes", "gauge", "Number of sandboxes registered by kicks.", status.sandboxes)
+
+	stats, err := k.stats()
+	if err != nil {
+		throttlerLog.WithError(err).Debug("could not get KSM stats")
+		return m.w.Flush()
+	}
+
+	m.metric("pages_shared", "gauge", "Number of shared KSM pages.", stats.pagesShared)
+	m.metric("pages_sharing", "gauge", "Number of sites sharing KSM pages.", stats.pagesSharing)
+	m.metric("pages_unshared", "gauge", "Number of unique pages repeatedly checked for merging.", stats.pagesUnshared)
+	m.metric("pages_volatile", "gauge", "Number of pages changing too fast to be merged.", stats.pagesVolatile)
+	m.metric("full_scans", "counter", "Number of times all mergeable areas have been scanned.", stats.fullScans)
+	m.metric("bytes_saved", "gauge", "Memory saved by KSM, in bytes.", stats.bytesSaved())
+
+	if stats.available(ksmStableNodeChains) {
+		m.metric("stable_node_chains", "gauge", "Number of stable node chains.", stats.stableNodeChains)
+	}
+
+	if stats.available(ksmGeneralProfit) {
+		m.metric("general_profit", "gauge", "KSM profit, in bytes.", stats.generalProfit)
+	}
+
+	return m.w.Flush()
+}
+
+func metricsHandler(k *ksm) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		if err := writeMetrics(w, k); err != nil {
+			throttlerLog.WithError(err).Error("could not write metrics")
+		}
+	})
+}
+
+// serveMetrics exposes the throttler metrics over HTTP, until ctx is done.
+func serveMetrics(ctx context.Context, addr string, k *ksm) error {
+	listen, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("Metrics listen error %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, metricsHandler(k))
+
+	server := &http.Server{Handler: mux}
+
+	go func() {
+		if err := server.Serve(listen); err != nil && err != http.ErrServerClosed {
+			throttlerLog.WithError(err).Error("metrics serve error")
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	return nil
+}
@@ -0,0 +1,74 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsCounter(t *testing.T) {
+	var c metricsCounter
+
+	assert.Equal(t, uint64(0), c.get())
+
+	c.inc()
+	c.inc()
+	assert.Equal(t, uint64(2), c.get())
+}
+
+func TestMetricsNoKSM(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	err := writeMetrics(buf, nil)
+	assert.Nil(t, err)
+
+	out := buf.String()
+	assert.True(t, strings.Contains(out, "# TYPE ksm_throttler_kicks_total counter\n"))
+	assert.True(t, strings.Contains(out, "ksm_throttler_tune_failures_total "))
+	assert.False(t, strings.Contains(out, "ksm_throttler_mode{"))
+}
+
+func TestMetricsHandler(t *testing.T) {
+	counters := map[string]string{
+		ksmPagesShared:      "10",
+		ksmPagesSharing:     "20",
+		ksmPagesUnshared:    "30",
+		ksmPagesVolatile:    "40",
+		ksmFullScans:        "5",
+		ksmStableNodeChains: "0",
+	}
+
+	err := writeKSMCounters(counters)
+	defer removeKSMCounters(counters)
+	assert.Nil(t, err)
+
+	k, err := startKSM(defaultKSMRoot, ksmStandard)
+	assert.Nil(t, err)
+	defer k.restore()
+
+	kicks := throttlerMetrics.kicks.get()
+	k.kick()
+	assert.Equal(t, kicks+1, throttlerMetrics.kicks.get())
+
+	recorder := httptest.NewRecorder()
+	metricsHandler(k).ServeHTTP(recorder, httptest.NewRequest("GET", metricsPath, nil))
+
+	out := recorder.Body.String()
+	assert.True(t, strings.Contains(out, "ksm_throttler_mode{mode=\"standard\"} 1\n"))
+	assert.True(t, strings.Contains(out, "ksm_throttler_mode{mode=\"aggressive\"} 0\n"))
+	assert.True(t, strings.Contains(out, "ksm_throttler_run 1\n"))
+	assert.True(t, strings.Contains(out, "ksm_throttler_sandboxes 0\n"))
+	assert.True(t, strings.Contains(out, "ksm_throttler_pages_shared 10\n"))
+	assert.True(t, strings.Contains(out, "ksm_throttler_full_scans 5\n"))
+	assert.True(t, strings.Contains(out, "ksm_throttler_stable_node_chains 0\n"))
+	assert.False(t, strings.Contains(out, "ksm_throttler_general_profit"))
+}
@@ -7,7 +7,7 @@
 package client
 
 import (
-	"fmt"
+	"errors"
 	"net"
 	"time"
 
@@ -15,28 +15,320 @@ import (
 	kpb "github.com/kata-containers/ksm-throttler/pkg/grpc"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-// Kick sends the gRPC Kick message to a KSM throttler service
-func Kick(uri string) error {
-	// Set up a connection to the server.
-	conn, err := grpc.Dial(uri, grpc.WithInsecure(), grpc.WithTimeout(5*time.Second),
+var (
+	// ErrNotRunning is returned when the KSM throttler can not be
+	// reached.
+	ErrNotRunning = errors.New("KSM throttler is not running")
+
+	// ErrKSMUnavailable is returned when the KSM throttler runs on
+	// a host without KSM, or after it restored the KSM settings.
+	ErrKSMUnavailable = errors.New("KSM is unavailable")
+
+	// ErrRateLimited is returned when the KSM throttler rejects a
+	// kick because the client kicks too often.
+	ErrRateLimited = errors.New("KSM throttler rate limit exceeded")
+
+	// ErrNotInitial is returned when re-baselining the initial KSM
+	// settings while the throttler runs other ones.
+	ErrNotInitial = errors.New("KSM does not run its initial settings")
+)
+
+// Options configures a Client.
+type Options struct {
+	// DialTimeout bounds each attempt to connect to the throttler.
+	DialTimeout time.Duration
+
+	// Retries is how many times a call is retried when the throttler
+	// can not be reached. A negative value disables the retries.
+	Retries int
+
+	// Backoff is the delay before the first retry. It doubles with
+	// each retry, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultOptions returns the options New uses for zero fields.
+func DefaultOptions() Options {
+	return Options{
+		DialTimeout: 5 * time.Second,
+		Retries:     3,
+		Backoff:     100 * time.Millisecond,
+		MaxBackoff:  2 * time.Second,
+	}
+}
+
+// Client is a KSM throttler gRPC client. It keeps its connection to the
+// throttler until closed, and is safe for concurrent use.
+type Client struct {
+	conn   *grpc.ClientConn
+	client kpb.KSMThrottlerClient
+	opts   Options
+}
+
+// New returns a client for the KSM throttler listening on the uri UNIX
+// socket. It does not wait for the throttler to be running: The
+// connection is established on the first call, and re-established
+// whenever the throttler restarts.
+func New(uri string, opts Options) (*Client, error) {
+	defaults := DefaultOptions()
+
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = defaults.DialTimeout
+	}
+
+	if opts.Retries == 0 {
+		opts.Retries = defaults.Retries
+	} else if opts.Retries < 0 {
+		opts.Retries = 0
+	}
+
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaults.Backoff
+	}
+
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaults.MaxBackoff
+	}
+
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = opts.Backoff
+	}
+
+	conn, err := grpc.Dial(uri, grpc.WithInsecure(),
+		grpc.WithBackoffMaxDelay(opts.MaxBackoff),
 		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			if timeout <= 0 || timeout > opts.DialTimeout {
+				timeout = opts.DialTimeout
+			}
+
 			return net.DialTimeout("unix", addr, timeout)
 		}))
 	if err != nil {
-		fmt.Printf("Dial error %v\n", err)
+		return nil, err
+	}
+
+	return &Client{
+		conn:   conn,
+		client: kpb.NewKSMThrottlerClient(conn),
+		opts:   opts,
+	}, nil
+}
+
+// Close closes the client connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// convertError maps the gRPC status codes to our errors.
+func convertError(err error) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	switch s.Code() {
+	case codes.OK:
+		return nil
+	case codes.Unavailable:
+		return ErrNotRunning
+	case codes.FailedPrecondition:
+		return ErrKSMUnavailable
+	case codes.ResourceExhausted:
+		return ErrRateLimited
+	case codes.Aborted:
+		return ErrNotInitial
+	}
+
+	return err
+}
+
+// call runs an RPC, retrying with backoff while the throttler can not
+// be reached.
+func (c *Client) call(ctx context.Context, rpc func(context.Context) error) error {
+	backoff := c.opts.Backoff
+
+	for attempt := 0; ; attempt++ {
+		err := convertError(rpc(ctx))
+		if err != ErrNotRunning || attempt >= c.opts.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > c.opts.MaxBackoff {
+			backoff = c.opts.MaxBackoff
+		}
+	}
+}
+
+// Kick throttles KSM up. req may be nil, for a default kick.
+func (c *Client) Kick(ctx context.Context, req *kpb.KickRequest) error {
+	if req == nil {
+		req = &kpb.KickRequest{}
+	}
+
+	return c.call(ctx, func(ctx context.Context) error {
+		_, err := c.client.Kick(ctx, req)
+		return err
+	})
+}
+
+// Status returns the throttler status.
+func (c *Client) Status(ctx context.Context) (*kpb.StatusResponse, error) {
+	var resp *kpb.StatusResponse
+
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.client.Status(ctx, &gpb.Empty{})
+		return err
+	})
+
+	return resp, err
+}
+
+// Stats returns the KSM merging counters.
+func (c *Client) Stats(ctx context.Context) (*kpb.Stats, error) {
+	var resp *kpb.Stats
+
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.client.GetStats(ctx, &gpb.Empty{})
+		return err
+	})
+
+	return resp, err
+}
+
+// SetMode moves the throttler to a KSM mode, and returns its new status.
+func (c *Client) SetMode(ctx context.Context, mode string) (*kpb.StatusResponse, error) {
+	var resp *kpb.StatusResponse
+
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.client.SetMode(ctx, &kpb.SetModeRequest{Mode: mode})
+		return err
+	})
+
+	return resp, err
+}
+
+// Rebaseline makes the current KSM settings the initial ones, and
+// returns the throttler new status.
+func (c *Client) Rebaseline(ctx context.Context) (*kpb.StatusResponse, error) {
+	var resp *kpb.StatusResponse
+
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.client.Rebaseline(ctx, &gpb.Empty{})
+		return err
+	})
+
+	return resp, err
+}
+
+// ProcessStats returns the KSM counters of a process.
+func (c *Client) ProcessStats(ctx context.Context, pid int) (*kpb.ProcessStats, error) {
+	var resp *kpb.ProcessStats
+
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.client.GetProcessStats(ctx, &kpb.ProcessRequest{Pid: int32(pid)})
+		return err
+	})
+
+	return resp, err
+}
+
+// ListSandboxes returns the KSM savings of the sandboxes that kicked the
+// throttler.
+func (c *Client) ListSandboxes(ctx context.Context) ([]*kpb.SandboxStats, error) {
+	var resp *kpb.ListSandboxesResponse
+
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		resp, err = c.client.ListSandboxes(ctx, &gpb.Empty{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Sandboxes, nil
+}
+
+// Release tells the throttler sandbox processes went away. The whole
+// sandbox is released when pids is empty.
+func (c *Client) Release(ctx context.Context, sandboxID string, pids []int32) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		_, err := c.client.Release(ctx, &kpb.ReleaseRequest{
+			SandboxId: sandboxID,
+			Pids:      pids,
+		})
+		return err
+	})
+}
+
+// EventStream receives the throttler events.
+type EventStream struct {
+	stream kpb.KSMThrottler_WatchClient
+}
+
+// Recv waits for the next event. It returns ErrNotRunning once the
+// throttler goes away.
+func (s *EventStream) Recv() (*kpb.Event, error) {
+	event, err := s.stream.Recv()
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	return event, nil
+}
+
+// Watch streams the throttler events, until ctx is cancelled.
+func (c *Client) Watch(ctx context.Context) (*EventStream, error) {
+	var stream kpb.KSMThrottler_WatchClient
+
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		stream, err = c.client.Watch(ctx, &gpb.Empty{})
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	defer conn.Close()
 
-	client := kpb.NewKSMThrottlerClient(conn)
+	return &EventStream{stream}, nil
+}
 
-	_, err = client.Kick(context.Background(), &gpb.Empty{})
+// Kick sends the gRPC Kick message to a KSM throttler service
+func Kick(uri string) error {
+	return KickSandbox(uri, "", nil)
+}
+
+// KickSandbox sends the gRPC Kick message to a KSM throttler service on
+// behalf of a sandbox. The throttler accounts the KSM savings of the
+// sandbox pids.
+func KickSandbox(uri, sandboxID string, pids []int32) error {
+	return SendKick(uri, &kpb.KickRequest{
+		SandboxId: sandboxID,
+		Pids:      pids,
+	})
+}
+
+// SendKick sends a gRPC Kick message to a KSM throttler service. The
+// request can set the KSM mode to move to and how long to hold it.
+// Callers kicking more than once should use a Client instead.
+func SendKick(uri string, req *kpb.KickRequest) error {
+	c, err := New(uri, Options{Retries: -1})
 	if err != nil {
-		fmt.Printf("kick err %v\n", err)
 		return err
 	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.DialTimeout)
+	defer cancel()
 
-	return nil
+	return c.Kick(ctx, req)
 }
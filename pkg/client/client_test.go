@@ -0,0 +1,255 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package client
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gpb "github.com/golang/protobuf/ptypes/empty"
+	kpb "github.com/kata-containers/ksm-throttler/pkg/grpc"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeThrottler records the requests it gets, and fails with err.
+type fakeThrottler struct {
+	kicks    []*kpb.KickRequest
+	releases []*kpb.ReleaseRequest
+	err      error
+}
+
+func (f *fakeThrottler) Kick(ctx context.Context, req *kpb.KickRequest) (*gpb.Empty, error) {
+	f.kicks = append(f.kicks, req)
+	return &gpb.Empty{}, f.err
+}
+
+func (f *fakeThrottler) Status(context.Context, *gpb.Empty) (*kpb.StatusResponse, error) {
+	return &kpb.StatusResponse{Mode: "aggressive"}, f.err
+}
+
+func (f *fakeThrottler) GetStats(context.Context, *gpb.Empty) (*kpb.Stats, error) {
+	return &kpb.Stats{PagesSharing: 42}, f.err
+}
+
+func (f *fakeThrottler) SetMode(ctx context.Context, req *kpb.SetModeRequest) (*kpb.StatusResponse, error) {
+	return &kpb.StatusResponse{Mode: req.Mode}, f.err
+}
+
+func (f *fakeThrottler) GetProcessStats(ctx context.Context, req *kpb.ProcessRequest) (*kpb.ProcessStats, error) {
+	return &kpb.ProcessStats{Pid: req.Pid}, f.err
+}
+
+func (f *fakeThrottler) ListSandboxes(context.Context, *gpb.Empty) (*kpb.ListSandboxesResponse, error) {
+	return &kpb.ListSandboxesResponse{
+		Sandboxes: []*kpb.SandboxStats{{SandboxId: "sandbox"}},
+	}, f.err
+}
+
+func (f *fakeThrottler) Watch(req *gpb.Empty, stream kpb.KSMThrottler_WatchServer) error {
+	if f.err != nil {
+		return f.err
+	}
+
+	return stream.Send(&kpb.Event{Type: kpb.Event_KICK})
+}
+
+func (f *fakeThrottler) Release(ctx context.Context, req *kpb.ReleaseRequest) (*gpb.Empty, error) {
+	f.releases = append(f.releases, req)
+	return &gpb.Empty{}, f.err
+}
+
+func (f *fakeThrottler) Rebaseline(context.Context, *gpb.Empty) (*kpb.StatusResponse, error) {
+	return &kpb.StatusResponse{Mode: "initial"}, f.err
+}
+
+func startFakeThrottler(t *testing.T, uri string, f *fakeThrottler) *grpc.Server {
+	listener, err := net.Listen("unix", uri)
+	assert.Nil(t, err)
+
+	server := grpc.NewServer()
+	kpb.RegisterKSMThrottlerServer(server, f)
+
+	go server.Serve(listener)
+
+	return server
+}
+
+func testURI(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "ksmthrottler-client")
+	assert.Nil(t, err)
+
+	return filepath.Join(dir, "ksm.sock"), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestClient(t *testing.T) {
+	uri, cleanup := testURI(t)
+	defer cleanup()
+
+	f := &fakeThrottler{}
+	server := startFakeThrottler(t, uri, f)
+	defer server.Stop()
+
+	c, err := New(uri, DefaultOptions())
+	assert.Nil(t, err)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	err = c.Kick(ctx, nil)
+	assert.Nil(t, err)
+
+	err = c.Kick(ctx, &kpb.KickRequest{Mode: "standard"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(f.kicks))
+	assert.Equal(t, "standard", f.kicks[1].Mode)
+
+	s, err := c.Status(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "aggressive", s.Mode)
+
+	stats, err := c.Stats(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(42), stats.PagesSharing)
+
+	s, err = c.SetMode(ctx, "slow")
+	assert.Nil(t, err)
+	assert.Equal(t, "slow", s.Mode)
+
+	p, err := c.ProcessStats(ctx, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(10), p.Pid)
+
+	sandboxes, err := c.ListSandboxes(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "sandbox", sandboxes[0].SandboxId)
+
+	err = c.Release(ctx, "sandbox", []int32{10})
+	assert.Nil(t, err)
+	assert.Equal(t, "sandbox", f.releases[0].SandboxId)
+	assert.Equal(t, []int32{10}, f.releases[0].Pids)
+
+	s, err = c.Rebaseline(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "initial", s.Mode)
+
+	events, err := c.Watch(ctx)
+	assert.Nil(t, err)
+
+	event, err := events.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, kpb.Event_KICK, event.Type)
+
+	err = SendKick(uri, &kpb.KickRequest{Reason: "test"})
+	assert.Nil(t, err)
+	assert.Equal(t, "test", f.kicks[2].Reason)
+}
+
+func TestClientErrors(t *testing.T) {
+	uri, cleanup := testURI(t)
+	defer cleanup()
+
+	f := &fakeThrottler{}
+	server := startFakeThrottler(t, uri, f)
+	defer server.Stop()
+
+	c, err := New(uri, DefaultOptions())
+	assert.Nil(t, err)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	f.err = status.Error(codes.FailedPrecondition, "KSM is unavailable")
+	_, err = c.Status(ctx)
+	assert.Equal(t, ErrKSMUnavailable, err)
+
+	f.err = status.Error(codes.ResourceExhausted, "Kick rate limit exceeded")
+	err = c.Kick(ctx, &kpb.KickRequest{})
+	assert.Equal(t, ErrRateLimited, err)
+
+	f.err = status.Error(codes.Aborted, "KSM does not run its initial settings")
+	_, err = c.Rebaseline(ctx)
+	assert.Equal(t, ErrNotInitial, err)
+
+	f.err = status.Error(codes.InvalidArgument, "Invalid KSM mode")
+	_, err = c.SetMode(ctx, "turbo")
+	s, ok := status.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, s.Code())
+
+	// Stream errors show up when receiving
+	f.err = errors.New("failure")
+	events, err := c.Watch(ctx)
+	assert.Nil(t, err)
+
+	_, err = events.Recv()
+	assert.NotNil(t, err)
+}
+
+func TestClientOptions(t *testing.T) {
+	uri, cleanup := testURI(t)
+	defer cleanup()
+
+	c, err := New(uri, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultOptions(), c.opts)
+	c.Close()
+
+	c, err = New(uri, Options{Retries: -1, Backoff: 5 * time.Second})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, c.opts.Retries)
+	assert.Equal(t, 5*time.Second, c.opts.MaxBackoff)
+	c.Close()
+}
+
+func TestClientNotRunning(t *testing.T) {
+	uri, cleanup := testURI(t)
+	defer cleanup()
+
+	c, err := New(uri, Options{
+		DialTimeout: 100 * time.Millisecond,
+		Retries:     2,
+		Backoff:     10 * time.Millisecond,
+	})
+	assert.Nil(t, err)
+	defer c.Close()
+
+	err = c.Kick(context.Background(), nil)
+	assert.Equal(t, ErrNotRunning, err)
+
+	// We retry until the daemon shows up
+	c.opts.Retries = 100
+	c.opts.Backoff = 50 * time.Millisecond
+	c.opts.MaxBackoff = 50 * time.Millisecond
+
+	f := &fakeThrottler{}
+	started := make(chan *grpc.Server, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		started <- startFakeThrottler(t, uri, f)
+	}()
+	defer func() {
+		(<-started).Stop()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+
+	err = c.Kick(ctx, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(f.kicks))
+}
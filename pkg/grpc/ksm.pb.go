@@ -8,13 +8,26 @@ It is generated from these files:
 	ksm.proto
 
 It has these top-level messages:
+	KickRequest
+	ReleaseRequest
+	SysfsValues
+	StatusResponse
+	SetModeRequest
+	Stats
+	ProcessRequest
+	ProcessStats
+	SandboxStats
+	ListSandboxesResponse
+	Event
 */
 package ksm
 
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
-import google_protobuf "github.com/golang/protobuf/ptypes/empty"
+import google_protobuf "github.com/golang/protobuf/ptypes/duration"
+import google_protobuf1 "github.com/golang/protobuf/ptypes/empty"
+import google_protobuf2 "github.com/golang/protobuf/ptypes/timestamp"
 
 import (
 	context "golang.org/x/net/context"
@@ -32,6 +45,577 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type Event_Type int32
+
+const (
+	Event_UNKNOWN Event_Type = 0
+	// KICK is sent for every kick, with kick set.
+	Event_KICK Event_Type = 1
+	// TRANSITION is sent when moving from a KSM mode to another,
+	// with old_mode, new_mode and values set.
+	Event_TRANSITION Event_Type = 2
+	// RESTORE is sent when restoring the initial KSM settings,
+	// with values set.
+	Event_RESTORE Event_Type = 3
+	// ERROR is sent when tuning or restoring KSM fails, with
+	// error set.
+	Event_ERROR Event_Type = 4
+)
+
+var Event_Type_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "KICK",
+	2: "TRANSITION",
+	3: "RESTORE",
+	4: "ERROR",
+}
+var Event_Type_value = map[string]int32{
+	"UNKNOWN":    0,
+	"KICK":       1,
+	"TRANSITION": 2,
+	"RESTORE":    3,
+	"ERROR":      4,
+}
+
+func (x Event_Type) String() string {
+	return proto.EnumName(Event_Type_name, int32(x))
+}
+func (Event_Type) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{10, 0} }
+
+// KickRequest throttles KSM up. All fields are optional, and an empty
+// KickRequest is encoded like the google.protobuf.Empty older clients
+// send. Kicks never throttle KSM down: When KSM already runs in a more
+// aggressive mode, the kick extends it instead.
+type KickRequest struct {
+	// sandbox_id identifies the sandbox kicking the throttler, for
+	// its KSM savings to be accounted.
+	SandboxId string `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId" json:"sandbox_id,omitempty"`
+	// pids are added to the sandbox processes, e.g. its VMM.
+	// They require a sandbox_id.
+	Pids []int32 `protobuf:"varint,2,rep,packed,name=pids" json:"pids,omitempty"`
+	// mode is one of slow, standard or aggressive. It defaults to the
+	// head of the throttling down chain, aggressive by default.
+	Mode string `protobuf:"bytes,3,opt,name=mode" json:"mode,omitempty"`
+	// hold is the minimum time to stay in mode before throttling
+	// down. It defaults to the time the throttling down chain holds
+	// mode.
+	Hold *google_protobuf.Duration `protobuf:"bytes,4,opt,name=hold" json:"hold,omitempty"`
+	// reason tells why the throttler got kicked, for logging purpose.
+	Reason string `protobuf:"bytes,5,opt,name=reason" json:"reason,omitempty"`
+}
+
+func (m *KickRequest) Reset()                    { *m = KickRequest{} }
+func (m *KickRequest) String() string            { return proto.CompactTextString(m) }
+func (*KickRequest) ProtoMessage()               {}
+func (*KickRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *KickRequest) GetSandboxId() string {
+	if m != nil {
+		return m.SandboxId
+	}
+	return ""
+}
+
+func (m *KickRequest) GetPids() []int32 {
+	if m != nil {
+		return m.Pids
+	}
+	return nil
+}
+
+func (m *KickRequest) GetMode() string {
+	if m != nil {
+		return m.Mode
+	}
+	return ""
+}
+
+func (m *KickRequest) GetHold() *google_protobuf.Duration {
+	if m != nil {
+		return m.Hold
+	}
+	return nil
+}
+
+func (m *KickRequest) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+// ReleaseRequest tells the throttler sandbox processes went away, e.g.
+// a VM exited. Releasing an unknown sandbox is not an error.
+type ReleaseRequest struct {
+	SandboxId string `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId" json:"sandbox_id,omitempty"`
+	// pids are removed from the sandbox processes. The whole sandbox
+	// is released when empty, or when no process is left.
+	Pids []int32 `protobuf:"varint,2,rep,packed,name=pids" json:"pids,omitempty"`
+	// reason tells why the sandbox is released, for logging purpose.
+	Reason string `protobuf:"bytes,3,opt,name=reason" json:"reason,omitempty"`
+}
+
+func (m *ReleaseRequest) Reset()                    { *m = ReleaseRequest{} }
+func (m *ReleaseRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReleaseRequest) ProtoMessage()               {}
+func (*ReleaseRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *ReleaseRequest) GetSandboxId() string {
+	if m != nil {
+		return m.SandboxId
+	}
+	return ""
+}
+
+func (m *ReleaseRequest) GetPids() []int32 {
+	if m != nil {
+		return m.Pids
+	}
+	return nil
+}
+
+func (m *ReleaseRequest) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+// SysfsValues holds the KSM sysfs attributes managed by the throttler.
+type SysfsValues struct {
+	Run            string `protobuf:"bytes,1,opt,name=run" json:"run,omitempty"`
+	PagesToScan    string `protobuf:"bytes,2,opt,name=pages_to_scan,json=pagesToScan" json:"pages_to_scan,omitempty"`
+	SleepMillisecs string `protobuf:"bytes,3,opt,name=sleep_millisecs,json=sleepMillisecs" json:"sleep_millisecs,omitempty"`
+	// knobs holds the advanced KSM attributes the kernel exports,
+	// e.g. merge_across_nodes or use_zero_pages.
+	Knobs map[string]string `protobuf:"bytes,4,rep,name=knobs" json:"knobs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *SysfsValues) Reset()                    { *m = SysfsValues{} }
+func (m *SysfsValues) String() string            { return proto.CompactTextString(m) }
+func (*SysfsValues) ProtoMessage()               {}
+func (*SysfsValues) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *SysfsValues) GetRun() string {
+	if m != nil {
+		return m.Run
+	}
+	return ""
+}
+
+func (m *SysfsValues) GetPagesToScan() string {
+	if m != nil {
+		return m.PagesToScan
+	}
+	return ""
+}
+
+func (m *SysfsValues) GetSleepMillisecs() string {
+	if m != nil {
+		return m.SleepMillisecs
+	}
+	return ""
+}
+
+func (m *SysfsValues) GetKnobs() map[string]string {
+	if m != nil {
+		return m.Knobs
+	}
+	return nil
+}
+
+type StatusResponse struct {
+	// mode is the KSM mode the throttler is currently in.
+	Mode string `protobuf:"bytes,1,opt,name=mode" json:"mode,omitempty"`
+	// throttling is true when the throttler reacts to kicks.
+	Throttling bool `protobuf:"varint,2,opt,name=throttling" json:"throttling,omitempty"`
+	// throttle_remaining is the time left before throttling down
+	// to the next mode. It is zero when no throttle down is pending.
+	ThrottleRemaining *google_protobuf.Duration `protobuf:"bytes,3,opt,name=throttle_remaining,json=throttleRemaining" json:"throttle_remaining,omitempty"`
+	// current holds the values currently written to sysfs.
+	Current *SysfsValues `protobuf:"bytes,4,opt,name=current" json:"current,omitempty"`
+	// initial holds the sysfs values found when the throttler started.
+	Initial *SysfsValues `protobuf:"bytes,5,opt,name=initial" json:"initial,omitempty"`
+	// policy is the mode the throttler has been asked to run in,
+	// e.g. auto.
+	Policy string `protobuf:"bytes,6,opt,name=policy" json:"policy,omitempty"`
+	// sandboxes is the number of sandboxes registered by kicks and not
+	// released yet.
+	Sandboxes int32 `protobuf:"varint,7,opt,name=sandboxes" json:"sandboxes,omitempty"`
+}
+
+func (m *StatusResponse) Reset()                    { *m = StatusResponse{} }
+func (m *StatusResponse) String() string            { return proto.CompactTextString(m) }
+func (*StatusResponse) ProtoMessage()               {}
+func (*StatusResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *StatusResponse) GetMode() string {
+	if m != nil {
+		return m.Mode
+	}
+	return ""
+}
+
+func (m *StatusResponse) GetThrottling() bool {
+	if m != nil {
+		return m.Throttling
+	}
+	return false
+}
+
+func (m *StatusResponse) GetThrottleRemaining() *google_protobuf.Duration {
+	if m != nil {
+		return m.ThrottleRemaining
+	}
+	return nil
+}
+
+func (m *StatusResponse) GetCurrent() *SysfsValues {
+	if m != nil {
+		return m.Current
+	}
+	return nil
+}
+
+func (m *StatusResponse) GetInitial() *SysfsValues {
+	if m != nil {
+		return m.Initial
+	}
+	return nil
+}
+
+func (m *StatusResponse) GetPolicy() string {
+	if m != nil {
+		return m.Policy
+	}
+	return ""
+}
+
+func (m *StatusResponse) GetSandboxes() int32 {
+	if m != nil {
+		return m.Sandboxes
+	}
+	return 0
+}
+
+type SetModeRequest struct {
+	// mode is one of initial, off, slow, standard, aggressive, auto,
+	// advisor, adaptive, controller or budget.
+	Mode string `protobuf:"bytes,1,opt,name=mode" json:"mode,omitempty"`
+}
+
+func (m *SetModeRequest) Reset()                    { *m = SetModeRequest{} }
+func (m *SetModeRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetModeRequest) ProtoMessage()               {}
+func (*SetModeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *SetModeRequest) GetMode() string {
+	if m != nil {
+		return m.Mode
+	}
+	return ""
+}
+
+// Stats holds the KSM counters from /sys/kernel/mm/ksm, see
+// https://www.kernel.org/doc/Documentation/vm/ksm.txt
+type Stats struct {
+	PagesShared      int64 `protobuf:"varint,1,opt,name=pages_shared,json=pagesShared" json:"pages_shared,omitempty"`
+	PagesSharing     int64 `protobuf:"varint,2,opt,name=pages_sharing,json=pagesSharing" json:"pages_sharing,omitempty"`
+	PagesUnshared    int64 `protobuf:"varint,3,opt,name=pages_unshared,json=pagesUnshared" json:"pages_unshared,omitempty"`
+	PagesVolatile    int64 `protobuf:"varint,4,opt,name=pages_volatile,json=pagesVolatile" json:"pages_volatile,omitempty"`
+	FullScans        int64 `protobuf:"varint,5,opt,name=full_scans,json=fullScans" json:"full_scans,omitempty"`
+	StableNodeChains int64 `protobuf:"varint,6,opt,name=stable_node_chains,json=stableNodeChains" json:"stable_node_chains,omitempty"`
+	GeneralProfit    int64 `protobuf:"varint,7,opt,name=general_profit,json=generalProfit" json:"general_profit,omitempty"`
+	// sharing_ratio is pages_sharing / pages_shared.
+	SharingRatio float64 `protobuf:"fixed64,8,opt,name=sharing_ratio,json=sharingRatio" json:"sharing_ratio,omitempty"`
+	// bytes_saved is the memory saved by KSM, in bytes.
+	BytesSaved int64 `protobuf:"varint,9,opt,name=bytes_saved,json=bytesSaved" json:"bytes_saved,omitempty"`
+	// unavailable lists the counters the kernel does not export.
+	Unavailable []string `protobuf:"bytes,10,rep,name=unavailable" json:"unavailable,omitempty"`
+}
+
+func (m *Stats) Reset()                    { *m = Stats{} }
+func (m *Stats) String() string            { return proto.CompactTextString(m) }
+func (*Stats) ProtoMessage()               {}
+func (*Stats) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *Stats) GetPagesShared() int64 {
+	if m != nil {
+		return m.PagesShared
+	}
+	return 0
+}
+
+func (m *Stats) GetPagesSharing() int64 {
+	if m != nil {
+		return m.PagesSharing
+	}
+	return 0
+}
+
+func (m *Stats) GetPagesUnshared() int64 {
+	if m != nil {
+		return m.PagesUnshared
+	}
+	return 0
+}
+
+func (m *Stats) GetPagesVolatile() int64 {
+	if m != nil {
+		return m.PagesVolatile
+	}
+	return 0
+}
+
+func (m *Stats) GetFullScans() int64 {
+	if m != nil {
+		return m.FullScans
+	}
+	return 0
+}
+
+func (m *Stats) GetStableNodeChains() int64 {
+	if m != nil {
+		return m.StableNodeChains
+	}
+	return 0
+}
+
+func (m *Stats) GetGeneralProfit() int64 {
+	if m != nil {
+		return m.GeneralProfit
+	}
+	return 0
+}
+
+func (m *Stats) GetSharingRatio() float64 {
+	if m != nil {
+		return m.SharingRatio
+	}
+	return 0
+}
+
+func (m *Stats) GetBytesSaved() int64 {
+	if m != nil {
+		return m.BytesSaved
+	}
+	return 0
+}
+
+func (m *Stats) GetUnavailable() []string {
+	if m != nil {
+		return m.Unavailable
+	}
+	return nil
+}
+
+type ProcessRequest struct {
+	Pid int32 `protobuf:"varint,1,opt,name=pid" json:"pid,omitempty"`
+}
+
+func (m *ProcessRequest) Reset()                    { *m = ProcessRequest{} }
+func (m *ProcessRequest) String() string            { return proto.CompactTextString(m) }
+func (*ProcessRequest) ProtoMessage()               {}
+func (*ProcessRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *ProcessRequest) GetPid() int32 {
+	if m != nil {
+		return m.Pid
+	}
+	return 0
+}
+
+// ProcessStats holds the KSM counters of a process, from
+// /proc/<pid>/ksm_stat. Counters the kernel does not export are zero.
+type ProcessStats struct {
+	Pid           int32 `protobuf:"varint,1,opt,name=pid" json:"pid,omitempty"`
+	MergingPages  int64 `protobuf:"varint,2,opt,name=merging_pages,json=mergingPages" json:"merging_pages,omitempty"`
+	RmapItems     int64 `protobuf:"varint,3,opt,name=rmap_items,json=rmapItems" json:"rmap_items,omitempty"`
+	ZeroPages     int64 `protobuf:"varint,4,opt,name=zero_pages,json=zeroPages" json:"zero_pages,omitempty"`
+	ProcessProfit int64 `protobuf:"varint,5,opt,name=process_profit,json=processProfit" json:"process_profit,omitempty"`
+}
+
+func (m *ProcessStats) Reset()                    { *m = ProcessStats{} }
+func (m *ProcessStats) String() string            { return proto.CompactTextString(m) }
+func (*ProcessStats) ProtoMessage()               {}
+func (*ProcessStats) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *ProcessStats) GetPid() int32 {
+	if m != nil {
+		return m.Pid
+	}
+	return 0
+}
+
+func (m *ProcessStats) GetMergingPages() int64 {
+	if m != nil {
+		return m.MergingPages
+	}
+	return 0
+}
+
+func (m *ProcessStats) GetRmapItems() int64 {
+	if m != nil {
+		return m.RmapItems
+	}
+	return 0
+}
+
+func (m *ProcessStats) GetZeroPages() int64 {
+	if m != nil {
+		return m.ZeroPages
+	}
+	return 0
+}
+
+func (m *ProcessStats) GetProcessProfit() int64 {
+	if m != nil {
+		return m.ProcessProfit
+	}
+	return 0
+}
+
+// SandboxStats holds the KSM savings of a sandbox.
+type SandboxStats struct {
+	SandboxId string `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId" json:"sandbox_id,omitempty"`
+	// merging_pages and process_profit are the sums of the sandbox
+	// processes counters.
+	MergingPages  int64           `protobuf:"varint,2,opt,name=merging_pages,json=mergingPages" json:"merging_pages,omitempty"`
+	ProcessProfit int64           `protobuf:"varint,3,opt,name=process_profit,json=processProfit" json:"process_profit,omitempty"`
+	Processes     []*ProcessStats `protobuf:"bytes,4,rep,name=processes" json:"processes,omitempty"`
+}
+
+func (m *SandboxStats) Reset()                    { *m = SandboxStats{} }
+func (m *SandboxStats) String() string            { return proto.CompactTextString(m) }
+func (*SandboxStats) ProtoMessage()               {}
+func (*SandboxStats) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *SandboxStats) GetSandboxId() string {
+	if m != nil {
+		return m.SandboxId
+	}
+	return ""
+}
+
+func (m *SandboxStats) GetMergingPages() int64 {
+	if m != nil {
+		return m.MergingPages
+	}
+	return 0
+}
+
+func (m *SandboxStats) GetProcessProfit() int64 {
+	if m != nil {
+		return m.ProcessProfit
+	}
+	return 0
+}
+
+func (m *SandboxStats) GetProcesses() []*ProcessStats {
+	if m != nil {
+		return m.Processes
+	}
+	return nil
+}
+
+type ListSandboxesResponse struct {
+	Sandboxes []*SandboxStats `protobuf:"bytes,1,rep,name=sandboxes" json:"sandboxes,omitempty"`
+}
+
+func (m *ListSandboxesResponse) Reset()                    { *m = ListSandboxesResponse{} }
+func (m *ListSandboxesResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListSandboxesResponse) ProtoMessage()               {}
+func (*ListSandboxesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *ListSandboxesResponse) GetSandboxes() []*SandboxStats {
+	if m != nil {
+		return m.Sandboxes
+	}
+	return nil
+}
+
+// Event describes something the throttler did. Watchers lagging too
+// far behind miss events.
+type Event struct {
+	Type    Event_Type                  `protobuf:"varint,1,opt,name=type,enum=ksm.Event_Type" json:"type,omitempty"`
+	Time    *google_protobuf2.Timestamp `protobuf:"bytes,2,opt,name=time" json:"time,omitempty"`
+	OldMode string                      `protobuf:"bytes,3,opt,name=old_mode,json=oldMode" json:"old_mode,omitempty"`
+	NewMode string                      `protobuf:"bytes,4,opt,name=new_mode,json=newMode" json:"new_mode,omitempty"`
+	// values holds the sysfs values after a transition or a restore.
+	Values *SysfsValues `protobuf:"bytes,5,opt,name=values" json:"values,omitempty"`
+	Kick   *KickRequest `protobuf:"bytes,6,opt,name=kick" json:"kick,omitempty"`
+	Error  string       `protobuf:"bytes,7,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *Event) Reset()                    { *m = Event{} }
+func (m *Event) String() string            { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()               {}
+func (*Event) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *Event) GetType() Event_Type {
+	if m != nil {
+		return m.Type
+	}
+	return Event_UNKNOWN
+}
+
+func (m *Event) GetTime() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Time
+	}
+	return nil
+}
+
+func (m *Event) GetOldMode() string {
+	if m != nil {
+		return m.OldMode
+	}
+	return ""
+}
+
+func (m *Event) GetNewMode() string {
+	if m != nil {
+		return m.NewMode
+	}
+	return ""
+}
+
+func (m *Event) GetValues() *SysfsValues {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
+func (m *Event) GetKick() *KickRequest {
+	if m != nil {
+		return m.Kick
+	}
+	return nil
+}
+
+func (m *Event) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*KickRequest)(nil), "ksm.KickRequest")
+	proto.RegisterType((*ReleaseRequest)(nil), "ksm.ReleaseRequest")
+	proto.RegisterType((*SysfsValues)(nil), "ksm.SysfsValues")
+	proto.RegisterType((*StatusResponse)(nil), "ksm.StatusResponse")
+	proto.RegisterType((*SetModeRequest)(nil), "ksm.SetModeRequest")
+	proto.RegisterType((*Stats)(nil), "ksm.Stats")
+	proto.RegisterType((*ProcessRequest)(nil), "ksm.ProcessRequest")
+	proto.RegisterType((*ProcessStats)(nil), "ksm.ProcessStats")
+	proto.RegisterType((*SandboxStats)(nil), "ksm.SandboxStats")
+	proto.RegisterType((*ListSandboxesResponse)(nil), "ksm.ListSandboxesResponse")
+	proto.RegisterType((*Event)(nil), "ksm.Event")
+	proto.RegisterEnum("ksm.Event_Type", Event_Type_name, Event_Type_value)
+}
+
 // Reference imports to suppress errors if they are not otherwise used.
 var _ context.Context
 var _ grpc.ClientConn
@@ -43,7 +627,15 @@ const _ = grpc.SupportPackageIsVersion4
 // Client API for KSMThrottler service
 
 type KSMThrottlerClient interface {
-	Kick(ctx context.Context, in *google_protobuf.Empty, opts ...grpc.CallOption) (*google_protobuf.Empty, error)
+	Kick(ctx context.Context, in *KickRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	Status(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*StatusResponse, error)
+	GetStats(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*Stats, error)
+	SetMode(ctx context.Context, in *SetModeRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	GetProcessStats(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*ProcessStats, error)
+	ListSandboxes(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ListSandboxesResponse, error)
+	Watch(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (KSMThrottler_WatchClient, error)
+	Release(ctx context.Context, in *ReleaseRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	Rebaseline(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*StatusResponse, error)
 }
 
 type kSMThrottlerClient struct {
@@ -54,8 +646,8 @@ func NewKSMThrottlerClient(cc *grpc.ClientConn) KSMThrottlerClient {
 	return &kSMThrottlerClient{cc}
 }
 
-func (c *kSMThrottlerClient) Kick(ctx context.Context, in *google_protobuf.Empty, opts ...grpc.CallOption) (*google_protobuf.Empty, error) {
-	out := new(google_protobuf.Empty)
+func (c *kSMThrottlerClient) Kick(ctx context.Context, in *KickRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
 	err := grpc.Invoke(ctx, "/ksm.KSMThrottler/Kick", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
@@ -63,10 +655,113 @@ func (c *kSMThrottlerClient) Kick(ctx context.Context, in *google_protobuf.Empty
 	return out, nil
 }
 
+func (c *kSMThrottlerClient) Status(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*StatusResponse, error) {
+	out := new(StatusResponse)
+	err := grpc.Invoke(ctx, "/ksm.KSMThrottler/Status", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kSMThrottlerClient) GetStats(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*Stats, error) {
+	out := new(Stats)
+	err := grpc.Invoke(ctx, "/ksm.KSMThrottler/GetStats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kSMThrottlerClient) SetMode(ctx context.Context, in *SetModeRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	out := new(StatusResponse)
+	err := grpc.Invoke(ctx, "/ksm.KSMThrottler/SetMode", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kSMThrottlerClient) GetProcessStats(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*ProcessStats, error) {
+	out := new(ProcessStats)
+	err := grpc.Invoke(ctx, "/ksm.KSMThrottler/GetProcessStats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kSMThrottlerClient) ListSandboxes(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ListSandboxesResponse, error) {
+	out := new(ListSandboxesResponse)
+	err := grpc.Invoke(ctx, "/ksm.KSMThrottler/ListSandboxes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kSMThrottlerClient) Watch(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (KSMThrottler_WatchClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_KSMThrottler_serviceDesc.Streams[0], c.cc, "/ksm.KSMThrottler/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kSMThrottlerWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KSMThrottler_WatchClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type kSMThrottlerWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *kSMThrottlerWatchClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kSMThrottlerClient) Release(ctx context.Context, in *ReleaseRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/ksm.KSMThrottler/Release", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kSMThrottlerClient) Rebaseline(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*StatusResponse, error) {
+	out := new(StatusResponse)
+	err := grpc.Invoke(ctx, "/ksm.KSMThrottler/Rebaseline", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for KSMThrottler service
 
 type KSMThrottlerServer interface {
-	Kick(context.Context, *google_protobuf.Empty) (*google_protobuf.Empty, error)
+	Kick(context.Context, *KickRequest) (*google_protobuf1.Empty, error)
+	Status(context.Context, *google_protobuf1.Empty) (*StatusResponse, error)
+	GetStats(context.Context, *google_protobuf1.Empty) (*Stats, error)
+	SetMode(context.Context, *SetModeRequest) (*StatusResponse, error)
+	GetProcessStats(context.Context, *ProcessRequest) (*ProcessStats, error)
+	ListSandboxes(context.Context, *google_protobuf1.Empty) (*ListSandboxesResponse, error)
+	Watch(*google_protobuf1.Empty, KSMThrottler_WatchServer) error
+	Release(context.Context, *ReleaseRequest) (*google_protobuf1.Empty, error)
+	Rebaseline(context.Context, *google_protobuf1.Empty) (*StatusResponse, error)
 }
 
 func RegisterKSMThrottlerServer(s *grpc.Server, srv KSMThrottlerServer) {
@@ -74,7 +769,7 @@ func RegisterKSMThrottlerServer(s *grpc.Server, srv KSMThrottlerServer) {
 }
 
 func _KSMThrottler_Kick_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(google_protobuf.Empty)
+	in := new(KickRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -86,7 +781,154 @@ func _KSMThrottler_Kick_Handler(srv interface{}, ctx context.Context, dec func(i
 		FullMethod: "/ksm.KSMThrottler/Kick",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(KSMThrottlerServer).Kick(ctx, req.(*google_protobuf.Empty))
+		return srv.(KSMThrottlerServer).Kick(ctx, req.(*KickRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KSMThrottler_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KSMThrottlerServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ksm.KSMThrottler/Status",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KSMThrottlerServer).Status(ctx, req.(*google_protobuf1.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KSMThrottler_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KSMThrottlerServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ksm.KSMThrottler/GetStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KSMThrottlerServer).GetStats(ctx, req.(*google_protobuf1.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KSMThrottler_SetMode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetModeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KSMThrottlerServer).SetMode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ksm.KSMThrottler/SetMode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KSMThrottlerServer).SetMode(ctx, req.(*SetModeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KSMThrottler_GetProcessStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProcessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KSMThrottlerServer).GetProcessStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ksm.KSMThrottler/GetProcessStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KSMThrottlerServer).GetProcessStats(ctx, req.(*ProcessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KSMThrottler_ListSandboxes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KSMThrottlerServer).ListSandboxes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ksm.KSMThrottler/ListSandboxes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KSMThrottlerServer).ListSandboxes(ctx, req.(*google_protobuf1.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KSMThrottler_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(google_protobuf1.Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KSMThrottlerServer).Watch(m, &kSMThrottlerWatchServer{stream})
+}
+
+type KSMThrottler_WatchServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type kSMThrottlerWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *kSMThrottlerWatchServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _KSMThrottler_Release_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KSMThrottlerServer).Release(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ksm.KSMThrottler/Release",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KSMThrottlerServer).Release(ctx, req.(*ReleaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KSMThrottler_Rebaseline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KSMThrottlerServer).Rebaseline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ksm.KSMThrottler/Rebaseline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KSMThrottlerServer).Rebaseline(ctx, req.(*google_protobuf1.Empty))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -99,20 +941,117 @@ var _KSMThrottler_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Kick",
 			Handler:    _KSMThrottler_Kick_Handler,
 		},
+		{
+			MethodName: "Status",
+			Handler:    _KSMThrottler_Status_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _KSMThrottler_GetStats_Handler,
+		},
+		{
+			MethodName: "SetMode",
+			Handler:    _KSMThrottler_SetMode_Handler,
+		},
+		{
+			MethodName: "GetProcessStats",
+			Handler:    _KSMThrottler_GetProcessStats_Handler,
+		},
+		{
+			MethodName: "ListSandboxes",
+			Handler:    _KSMThrottler_ListSandboxes_Handler,
+		},
+		{
+			MethodName: "Release",
+			Handler:    _KSMThrottler_Release_Handler,
+		},
+		{
+			MethodName: "Rebaseline",
+			Handler:    _KSMThrottler_Rebaseline_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _KSMThrottler_Watch_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "ksm.proto",
 }
 
 func init() { proto.RegisterFile("ksm.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 106 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0xcc, 0x2e, 0xce, 0xd5,
-	0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0xce, 0x2e, 0xce, 0x95, 0x92, 0x4e, 0xcf, 0xcf, 0x4f,
-	0xcf, 0x49, 0xd5, 0x07, 0x0b, 0x25, 0x95, 0xa6, 0xe9, 0xa7, 0xe6, 0x16, 0x94, 0x54, 0x42, 0x54,
-	0x18, 0xb9, 0x71, 0xf1, 0x78, 0x07, 0xfb, 0x86, 0x64, 0x14, 0xe5, 0x97, 0x94, 0xe4, 0xa4, 0x16,
-	0x09, 0x99, 0x71, 0xb1, 0x78, 0x67, 0x26, 0x67, 0x0b, 0x89, 0xe9, 0x41, 0x74, 0xe9, 0xc1, 0x74,
-	0xe9, 0xb9, 0x82, 0x74, 0x49, 0xe1, 0x10, 0x4f, 0x62, 0x03, 0xf3, 0x8d, 0x01, 0x01, 0x00, 0x00,
-	0xff, 0xff, 0x56, 0x33, 0xff, 0x66, 0x7d, 0x00, 0x00, 0x00,
+	// 1109 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x56, 0xdd, 0x8e, 0xdb, 0x44,
+	0x14, 0xc6, 0xb1, 0x9d, 0x6c, 0x4e, 0x76, 0xd3, 0x74, 0x0a, 0x95, 0x9b, 0x42, 0x1b, 0xd2, 0x22,
+	0x22, 0x44, 0xb3, 0x65, 0x2b, 0x50, 0xcb, 0x1d, 0x2a, 0x51, 0xbb, 0x0a, 0xcd, 0x56, 0x93, 0xb4,
+	0xbd, 0xe0, 0x22, 0x9a, 0xc4, 0x67, 0xb3, 0x56, 0x1c, 0x8f, 0xf1, 0x4c, 0xb6, 0x84, 0x57, 0x81,
+	0x27, 0xe0, 0x25, 0x78, 0x0e, 0x1e, 0x85, 0x1b, 0x84, 0xe6, 0xc7, 0x1b, 0x67, 0xb3, 0xd1, 0x82,
+	0xb8, 0xf3, 0x7c, 0xe7, 0x3b, 0x67, 0xce, 0xff, 0x18, 0xaa, 0x73, 0xb1, 0xe8, 0xa6, 0x19, 0x97,
+	0x9c, 0xb8, 0x73, 0xb1, 0x68, 0xde, 0x9b, 0x71, 0x3e, 0x8b, 0xf1, 0x50, 0x43, 0x93, 0xe5, 0xe9,
+	0x61, 0xb8, 0xcc, 0x98, 0x8c, 0x78, 0x62, 0x48, 0xcd, 0xbb, 0x97, 0xe5, 0xb8, 0x48, 0xe5, 0xca,
+	0x0a, 0xef, 0x5f, 0x16, 0xca, 0x68, 0x81, 0x42, 0xb2, 0x45, 0x6a, 0x08, 0xed, 0x5f, 0x1d, 0xa8,
+	0xf5, 0xa3, 0xe9, 0x9c, 0xe2, 0x4f, 0x4b, 0x14, 0x92, 0x7c, 0x02, 0x20, 0x58, 0x12, 0x4e, 0xf8,
+	0xcf, 0xe3, 0x28, 0x0c, 0x9c, 0x96, 0xd3, 0xa9, 0xd2, 0xaa, 0x45, 0x8e, 0x43, 0x42, 0xc0, 0x4b,
+	0xa3, 0x50, 0x04, 0xa5, 0x96, 0xdb, 0xf1, 0xa9, 0xfe, 0x56, 0xd8, 0x82, 0x87, 0x18, 0xb8, 0x9a,
+	0xac, 0xbf, 0xc9, 0x23, 0xf0, 0xce, 0x78, 0x1c, 0x06, 0x5e, 0xcb, 0xe9, 0xd4, 0x8e, 0xee, 0x74,
+	0x8d, 0x1b, 0xdd, 0xdc, 0x8d, 0xee, 0xf7, 0x36, 0x06, 0xaa, 0x69, 0xe4, 0x36, 0x94, 0x33, 0x64,
+	0x82, 0x27, 0x81, 0xaf, 0x8d, 0xd8, 0x53, 0xfb, 0x47, 0xa8, 0x53, 0x8c, 0x91, 0x09, 0xfc, 0x1f,
+	0xfe, 0xad, 0x8d, 0xbb, 0x1b, 0xc6, 0xff, 0x74, 0xa0, 0x36, 0x5c, 0x89, 0x53, 0xf1, 0x96, 0xc5,
+	0x4b, 0x14, 0xa4, 0x01, 0x6e, 0xb6, 0x4c, 0xac, 0x4d, 0xf5, 0x49, 0xda, 0x70, 0x90, 0xb2, 0x19,
+	0x8a, 0xb1, 0xe4, 0x63, 0x31, 0x65, 0x49, 0x50, 0xd2, 0xb2, 0x9a, 0x06, 0x47, 0x7c, 0x38, 0x65,
+	0x09, 0xf9, 0x1c, 0x6e, 0x88, 0x18, 0x31, 0x1d, 0x2f, 0xa2, 0x38, 0x8e, 0x04, 0x4e, 0x85, 0xbd,
+	0xa6, 0xae, 0xe1, 0x57, 0x39, 0x4a, 0xbe, 0x02, 0x7f, 0x9e, 0xf0, 0x89, 0x08, 0xbc, 0x96, 0xdb,
+	0xa9, 0x1d, 0xdd, 0xed, 0xaa, 0x3a, 0x17, 0xee, 0xef, 0xf6, 0x95, 0xb4, 0x97, 0xc8, 0x6c, 0x45,
+	0x0d, 0xb3, 0xf9, 0x14, 0x60, 0x0d, 0x2a, 0xff, 0xe6, 0xb8, 0xca, 0xfd, 0x9b, 0xe3, 0x8a, 0x7c,
+	0x08, 0xfe, 0xb9, 0xd2, 0xb5, 0x7e, 0x99, 0xc3, 0xb7, 0xa5, 0xa7, 0x4e, 0xfb, 0xb7, 0x12, 0xd4,
+	0x87, 0x92, 0xc9, 0xa5, 0xa0, 0x28, 0x52, 0x9e, 0x08, 0xbc, 0x28, 0x93, 0x53, 0x28, 0xd3, 0x3d,
+	0x00, 0x79, 0x96, 0x71, 0x29, 0xe3, 0x28, 0x99, 0x69, 0x2b, 0x7b, 0xb4, 0x80, 0x90, 0x97, 0x40,
+	0xec, 0x09, 0xc7, 0x19, 0x2e, 0x58, 0x94, 0x28, 0x9e, 0x7b, 0x5d, 0x51, 0x6f, 0xe6, 0x4a, 0x34,
+	0xd7, 0x21, 0x5f, 0x40, 0x65, 0xba, 0xcc, 0x32, 0x4c, 0xa4, 0xed, 0x89, 0xc6, 0xe5, 0xf8, 0x69,
+	0x4e, 0x50, 0xdc, 0x28, 0x89, 0x64, 0xc4, 0xe2, 0xc0, 0xdf, 0xc5, 0xb5, 0x04, 0x55, 0xdc, 0x94,
+	0xc7, 0xd1, 0x74, 0x15, 0x94, 0x4d, 0x71, 0xcd, 0x89, 0x7c, 0x0c, 0x79, 0x57, 0xa0, 0x08, 0x2a,
+	0x2d, 0xa7, 0xe3, 0xd3, 0x35, 0xd0, 0x7e, 0x08, 0xf5, 0x21, 0xca, 0x57, 0x3c, 0xbc, 0xe8, 0xab,
+	0x2b, 0xb2, 0xd3, 0xfe, 0xab, 0x04, 0xbe, 0x4a, 0xa2, 0x20, 0x9f, 0xc2, 0xbe, 0x69, 0x04, 0x71,
+	0xc6, 0x32, 0x34, 0x7d, 0xe7, 0xda, 0x3e, 0x18, 0x6a, 0x88, 0x3c, 0x80, 0x83, 0x35, 0x25, 0xcf,
+	0xa6, 0x4b, 0xf7, 0x2f, 0x38, 0x2a, 0x0b, 0x9f, 0x41, 0xdd, 0x90, 0x96, 0x89, 0xb5, 0xe4, 0x6a,
+	0x96, 0x51, 0x7d, 0x63, 0xc1, 0x35, 0xed, 0x9c, 0xc7, 0x4c, 0x46, 0x31, 0x06, 0x5e, 0x81, 0xf6,
+	0xd6, 0x82, 0x6a, 0x16, 0x4e, 0x97, 0x71, 0xac, 0x5b, 0x53, 0xe8, 0x54, 0xb9, 0xb4, 0xaa, 0x10,
+	0xd5, 0x98, 0x82, 0x7c, 0x09, 0x44, 0x48, 0x36, 0x89, 0x71, 0x9c, 0xf0, 0x10, 0xc7, 0xd3, 0x33,
+	0x16, 0x25, 0x42, 0xa7, 0xc9, 0xa5, 0x0d, 0x23, 0x19, 0xf0, 0x10, 0x9f, 0x6b, 0x5c, 0xdd, 0x39,
+	0xc3, 0x04, 0x33, 0x16, 0x8f, 0xd3, 0x8c, 0x9f, 0x46, 0x52, 0x67, 0xcd, 0xa5, 0x07, 0x16, 0x7d,
+	0xad, 0x41, 0x15, 0xa6, 0x0d, 0x70, 0xac, 0x8b, 0x1d, 0xec, 0xb5, 0x9c, 0x8e, 0x43, 0xf7, 0x2d,
+	0x48, 0x15, 0x46, 0xee, 0x43, 0x6d, 0xb2, 0x92, 0x2a, 0x17, 0xec, 0x1c, 0xc3, 0xa0, 0xaa, 0x0d,
+	0x81, 0x86, 0x86, 0x0a, 0x21, 0x2d, 0xa8, 0x2d, 0x13, 0x76, 0xce, 0xa2, 0x58, 0x79, 0x11, 0x40,
+	0xcb, 0x55, 0x63, 0x55, 0x80, 0xda, 0x6d, 0xa8, 0xbf, 0xce, 0xf8, 0x14, 0x85, 0xc8, 0x2b, 0xd4,
+	0x00, 0x37, 0xb5, 0x23, 0xef, 0x53, 0xf5, 0xd9, 0xfe, 0xdd, 0x81, 0x7d, 0x4b, 0x32, 0x65, 0xda,
+	0xa2, 0x28, 0x77, 0x17, 0x98, 0xcd, 0x94, 0xbb, 0x3a, 0x77, 0x79, 0x55, 0x2c, 0xf8, 0x5a, 0x61,
+	0x2a, 0x8f, 0xd9, 0x82, 0xa5, 0xe3, 0x48, 0xe2, 0x42, 0xd8, 0x8a, 0x54, 0x15, 0x72, 0xac, 0x00,
+	0x25, 0xfe, 0x05, 0x33, 0x6e, 0x0d, 0x98, 0x4a, 0x54, 0x15, 0x62, 0xb4, 0x55, 0xb1, 0x8c, 0x13,
+	0x79, 0xe2, 0x7c, 0x5b, 0x2c, 0x83, 0x9a, 0xc4, 0x69, 0x67, 0x87, 0xa6, 0x01, 0x8d, 0xb3, 0xd7,
+	0x6c, 0xb2, 0x7f, 0xe5, 0xf9, 0xf6, 0xdd, 0xee, 0x15, 0x77, 0x93, 0x43, 0xa8, 0x5a, 0x00, 0xf3,
+	0xf5, 0x73, 0x53, 0x8f, 0x54, 0x31, 0x7b, 0x74, 0xcd, 0x69, 0xbf, 0x84, 0x8f, 0x7e, 0x88, 0x84,
+	0x1c, 0xe6, 0x03, 0x73, 0xb1, 0x44, 0x0e, 0x8b, 0x63, 0xe5, 0x14, 0x2c, 0x15, 0x43, 0x2b, 0x4e,
+	0xda, 0x1f, 0x25, 0xf0, 0x7b, 0xe7, 0x6a, 0xaa, 0x1f, 0x80, 0x27, 0x57, 0xa9, 0x99, 0xb0, 0xfa,
+	0xd1, 0x0d, 0xad, 0xa5, 0x25, 0xdd, 0xd1, 0x2a, 0x45, 0xaa, 0x85, 0xa4, 0x0b, 0x9e, 0x7a, 0xa1,
+	0x74, 0xb0, 0xb5, 0xa3, 0xe6, 0xd6, 0x8a, 0x19, 0xe5, 0xcf, 0x17, 0xd5, 0x3c, 0x72, 0x07, 0xf6,
+	0x78, 0x1c, 0x8e, 0x0b, 0xef, 0x4f, 0x85, 0xc7, 0xa1, 0x1a, 0x6c, 0x25, 0x4a, 0xf0, 0xbd, 0x11,
+	0x79, 0x46, 0x94, 0xe0, 0x7b, 0x2d, 0xea, 0x40, 0x59, 0xaf, 0x4a, 0xb1, 0x73, 0xbf, 0x58, 0x39,
+	0x79, 0x08, 0xde, 0x3c, 0x9a, 0xce, 0x83, 0x72, 0x81, 0x57, 0x78, 0x2e, 0xa9, 0x96, 0xaa, 0x3d,
+	0x8c, 0x59, 0xc6, 0x33, 0x3d, 0x32, 0x55, 0x6a, 0x0e, 0xed, 0x1e, 0x78, 0x2a, 0x32, 0x52, 0x83,
+	0xca, 0x9b, 0x41, 0x7f, 0x70, 0xf2, 0x6e, 0xd0, 0xf8, 0x80, 0xec, 0x81, 0xd7, 0x3f, 0x7e, 0xde,
+	0x6f, 0x38, 0xa4, 0x0e, 0x30, 0xa2, 0xdf, 0x0d, 0x86, 0xc7, 0xa3, 0xe3, 0x93, 0x41, 0xa3, 0xa4,
+	0x68, 0xb4, 0x37, 0x1c, 0x9d, 0xd0, 0x5e, 0xc3, 0x25, 0x55, 0xf0, 0x7b, 0x94, 0x9e, 0xd0, 0x86,
+	0x77, 0xf4, 0xb7, 0x0b, 0xfb, 0xfd, 0xe1, 0xab, 0x91, 0x5d, 0xa9, 0x19, 0x79, 0x0c, 0x9e, 0x72,
+	0x81, 0x6c, 0x79, 0xd3, 0xbc, 0xbd, 0x95, 0xaf, 0x9e, 0xfa, 0x17, 0x20, 0x5f, 0x43, 0xd9, 0x3c,
+	0x06, 0x64, 0x07, 0xa3, 0x79, 0xcb, 0x64, 0x60, 0xf3, 0xc5, 0xe8, 0xc2, 0xde, 0x0b, 0x94, 0xa6,
+	0x5b, 0x77, 0x29, 0xc2, 0x85, 0xa2, 0x20, 0x4f, 0xa0, 0x62, 0xb7, 0x2a, 0xb1, 0xf6, 0x36, 0x76,
+	0xec, 0xd5, 0x97, 0x3c, 0x83, 0x1b, 0x2f, 0x50, 0x6e, 0x8c, 0xf1, 0xad, 0x62, 0x6f, 0xe6, 0xca,
+	0xdb, 0x0d, 0x4b, 0x9e, 0xc3, 0xc1, 0x46, 0x97, 0xee, 0x74, 0xb2, 0xa9, 0x75, 0xaf, 0xee, 0xe8,
+	0x47, 0xe0, 0xbf, 0x63, 0x72, 0x7a, 0x76, 0x4d, 0x84, 0xba, 0x53, 0x1f, 0x3b, 0xe4, 0x1b, 0xa8,
+	0xd8, 0x3f, 0x12, 0xeb, 0xe6, 0xe6, 0xff, 0xc9, 0xce, 0x12, 0x3c, 0x03, 0xa0, 0x38, 0x61, 0x02,
+	0xe3, 0x28, 0xc1, 0xff, 0x54, 0x86, 0x49, 0x59, 0x93, 0x9e, 0xfc, 0x33, 0x00, 0xc5, 0xa5, 0x81,
+	0x6c, 0x19, 0x0a, 0x00, 0x00,
 }
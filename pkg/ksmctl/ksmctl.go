@@ -0,0 +1,600 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package ksmctl implements the ksmctl command line tool, to inspect and
+// drive a KSM throttler.
+package ksmctl
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/kata-containers/ksm-throttler/pkg/client"
+	kpb "github.com/kata-containers/ksm-throttler/pkg/grpc"
+	"golang.org/x/net/context"
+)
+
+// ksmctl exit codes
+const (
+	ExitOK             = 0
+	ExitFailure        = 1
+	ExitUsage          = 2
+	ExitNotRunning     = 3
+	ExitKSMUnavailable = 4
+)
+
+// Config describes the ksmctl environment.
+type Config struct {
+	// URI is the default KSM throttler socket, overridden by -uri.
+	URI string
+
+	// Version is the ksmctl version.
+	Version string
+
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(c *cli, args []string) error
+}
+
+var commands = []command{
+	{"kick", "[-mode MODE] [-hold DURATION] [-reason REASON] [-sandbox ID [PID...]]", "throttle KSM up", kick},
+	{"status", "", "show the throttler status", status},
+	{"stats", "", "show the KSM merging counters", stats},
+	{"set-mode", "MODE", "move the throttler to a KSM mode", setMode},
+	{"restore", "", "restore the initial KSM settings and stop throttling", restore},
+	{"rebaseline", "", "make the current KSM settings the initial ones", rebaseline},
+	{"watch", "[-n COUNT]", "show the throttler events as they happen", watch},
+	{"version", "", "show the ksmctl version", version},
+}
+
+// usageError is returned for invalid command lines.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+type cli struct {
+	Config
+
+	ctx     context.Context
+	uri     string
+	json    bool
+	timeout time.Duration
+}
+
+func (c *cli) usage() {
+	fmt.Fprintf(c.Stderr, "Usage: ksmctl [-uri URI] [-json] [-timeout DURATION] COMMAND [ARGS]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(c.Stderr, "  %-9s %s\n", cmd.name, cmd.summary)
+		if cmd.args != "" {
+			fmt.Fprintf(c.Stderr, "            %s %s\n", cmd.name, cmd.args)
+		}
+	}
+}
+
+// Run runs ksmctl with the command line arguments args, without the
+// program name, until done or until ctx is cancelled. It returns the
+// ksmctl exit code.
+func Run(ctx context.Context, config Config, args []string) int {
+	c := &cli{Config: config, ctx: ctx}
+
+	flags := flag.NewFlagSet("ksmctl", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	flags.StringVar(&c.uri, "uri", config.URI, "KSM throttler gRPC URI")
+	flags.BoolVar(&c.json, "json", false, "JSON output")
+	flags.DurationVar(&c.timeout, "timeout", 10*time.Second, "KSM throttler calls timeout")
+
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintln(c.Stderr, err)
+		c.usage()
+		return ExitUsage
+	}
+
+	if flags.NArg() == 0 {
+		c.usage()
+		return ExitUsage
+	}
+
+	name := flags.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+
+		return c.exitCode(cmd.run(c, flags.Args()[1:]))
+	}
+
+	fmt.Fprintf(c.Stderr, "Unknown command %q\n", name)
+	c.usage()
+
+	return ExitUsage
+}
+
+func (c *cli) exitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	fmt.Fprintf(c.Stderr, "ksmctl: %v\n", err)
+
+	switch err.(type) {
+	case usageError:
+		return ExitUsage
+	}
+
+	switch err {
+	case client.ErrNotRunning:
+		return ExitNotRunning
+	case client.ErrKSMUnavailable:
+		return ExitKSMUnavailable
+	}
+
+	return ExitFailure
+}
+
+func (c *cli) client() (*client.Client, error) {
+	return client.New(c.uri, client.DefaultOptions())
+}
+
+// callContext bounds a throttler call with our timeout.
+func (c *cli) callContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.ctx, c.timeout)
+}
+
+// parseFlags parses the command flags, and checks it got the expected
+// number of arguments, -1 standing for any.
+func (c *cli) parseFlags(flags *flag.FlagSet, args []string, nArgs int) error {
+	flags.SetOutput(ioutil.Discard)
+
+	if err := flags.Parse(args); err != nil {
+		return usageError{err.Error()}
+	}
+
+	if nArgs >= 0 && flags.NArg() != nArgs {
+		return usageError{fmt.Sprintf("%s takes %d argument(s)", flags.Name(), nArgs)}
+	}
+
+	return nil
+}
+
+func (c *cli) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(c.Stdout)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
+
+func kick(c *cli, args []string) error {
+	flags := flag.NewFlagSet("kick", flag.ContinueOnError)
+	mode := flags.String("mode", "", "KSM mode to move to; one of slow, standard or aggressive")
+	hold := flags.Duration("hold", 0, "minimum time to hold the KSM mode")
+	reason := flags.String("reason", "", "why the throttler gets kicked")
+	sandbox := flags.String("sandbox", "", "ID of the sandbox kicking the throttler, followed by its PIDs")
+
+	if err := c.parseFlags(flags, args, -1); err != nil {
+		return err
+	}
+
+	req := &kpb.KickRequest{
+		SandboxId: *sandbox,
+		Mode:      *mode,
+		Reason:    *reason,
+	}
+
+	if *hold != 0 {
+		req.Hold = ptypes.DurationProto(*hold)
+	}
+
+	for _, arg := range flags.Args() {
+		pid, err := strconv.ParseInt(arg, 10, 32)
+		if err != nil {
+			return usageError{fmt.Sprintf("Invalid PID %s", arg)}
+		}
+
+		req.Pids = append(req.Pids, int32(pid))
+	}
+
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	return cl.Kick(ctx, req)
+}
+
+func (c *cli) printStatus(s *kpb.StatusResponse) error {
+	status := newStatusJSON(s)
+
+	if c.json {
+		return c.printJSON(status)
+	}
+
+	fmt.Fprintf(c.Stdout, "Mode:       %s\n", status.Mode)
+	fmt.Fprintf(c.Stdout, "Policy:     %s\n", status.Policy)
+	fmt.Fprintf(c.Stdout, "Throttling: %v\n", status.Throttling)
+	if status.ThrottleRemaining != "" {
+		fmt.Fprintf(c.Stdout, "Remaining:  %s\n", status.ThrottleRemaining)
+	}
+	fmt.Fprintf(c.Stdout, "Sandboxes:  %d\n", status.Sandboxes)
+	fmt.Fprintf(c.Stdout, "Current:    %s\n", status.Current)
+	fmt.Fprintf(c.Stdout, "Initial:    %s\n", status.Initial)
+
+	return nil
+}
+
+func status(c *cli, args []string) error {
+	if err := c.parseFlags(flag.NewFlagSet("status", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	s, err := cl.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.printStatus(s)
+}
+
+func stats(c *cli, args []string) error {
+	if err := c.parseFlags(flag.NewFlagSet("stats", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	s, err := cl.Stats(ctx)
+	if err != nil {
+		return err
+	}
+
+	stats := newStatsJSON(s)
+
+	if c.json {
+		return c.printJSON(stats)
+	}
+
+	fmt.Fprintf(c.Stdout, "pages_shared:       %d\n", stats.PagesShared)
+	fmt.Fprintf(c.Stdout, "pages_sharing:      %d\n", stats.PagesSharing)
+	fmt.Fprintf(c.Stdout, "pages_unshared:     %d\n", stats.PagesUnshared)
+	fmt.Fprintf(c.Stdout, "pages_volatile:     %d\n", stats.PagesVolatile)
+	fmt.Fprintf(c.Stdout, "full_scans:         %d\n", stats.FullScans)
+	fmt.Fprintf(c.Stdout, "stable_node_chains: %d\n", stats.StableNodeChains)
+	fmt.Fprintf(c.Stdout, "general_profit:     %d\n", stats.GeneralProfit)
+	fmt.Fprintf(c.Stdout, "sharing_ratio:      %.2f\n", stats.SharingRatio)
+	fmt.Fprintf(c.Stdout, "bytes_saved:        %d\n", stats.BytesSaved)
+	if len(stats.Unavailable) > 0 {
+		fmt.Fprintf(c.Stdout, "unavailable:        %s\n", strings.Join(stats.Unavailable, ", "))
+	}
+
+	return nil
+}
+
+func (c *cli) setMode(mode string) error {
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	s, err := cl.SetMode(ctx, mode)
+	if err != nil {
+		return err
+	}
+
+	return c.printStatus(s)
+}
+
+func setMode(c *cli, args []string) error {
+	flags := flag.NewFlagSet("set-mode", flag.ContinueOnError)
+	if err := c.parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	return c.setMode(flags.Arg(0))
+}
+
+func restore(c *cli, args []string) error {
+	if err := c.parseFlags(flag.NewFlagSet("restore", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+
+	return c.setMode("initial")
+}
+
+func rebaseline(c *cli, args []string) error {
+	if err := c.parseFlags(flag.NewFlagSet("rebaseline", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	ctx, cancel := c.callContext()
+	defer cancel()
+
+	s, err := cl.Rebaseline(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.printStatus(s)
+}
+
+func watch(c *cli, args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	count := flags.Int("n", 0, "exit after COUNT events, 0 meaning never")
+
+	if err := c.parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	defer cancel()
+
+	events, err := cl.Watch(ctx)
+	if err != nil {
+		return err
+	}
+
+	for n := 0; *count == 0 || n < *count; n++ {
+		e, err := events.Recv()
+		if err != nil {
+			// We got interrupted
+			if c.ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		event := newEventJSON(e)
+
+		if c.json {
+			if err := json.NewEncoder(c.Stdout).Encode(event); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fmt.Fprintln(c.Stdout, event)
+	}
+
+	return nil
+}
+
+func version(c *cli, args []string) error {
+	if err := c.parseFlags(flag.NewFlagSet("version", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(map[string]string{"version": c.Version})
+	}
+
+	fmt.Fprintf(c.Stdout, "ksmctl version %s\n", c.Version)
+
+	return nil
+}
+
+// The JSON output types. We don't rely on the gRPC types encoding, to
+// keep zero values and to get readable durations and times.
+
+type sysfsJSON struct {
+	Run            string            `json:"run"`
+	PagesToScan    string            `json:"pages_to_scan"`
+	SleepMillisecs string            `json:"sleep_millisecs"`
+	Knobs          map[string]string `json:"knobs,omitempty"`
+}
+
+func newSysfsJSON(v *kpb.SysfsValues) *sysfsJSON {
+	if v == nil {
+		return nil
+	}
+
+	return &sysfsJSON{
+		Run:            v.Run,
+		PagesToScan:    v.PagesToScan,
+		SleepMillisecs: v.SleepMillisecs,
+		Knobs:          v.Knobs,
+	}
+}
+
+func (v *sysfsJSON) String() string {
+	if v == nil {
+		return "-"
+	}
+
+	s := fmt.Sprintf("run=%s pages_to_scan=%s sleep_millisecs=%s", v.Run, v.PagesToScan, v.SleepMillisecs)
+
+	var knobs []string
+	for name, value := range v.Knobs {
+		knobs = append(knobs, name+"="+value)
+	}
+	sort.Strings(knobs)
+
+	if len(knobs) > 0 {
+		s += " " + strings.Join(knobs, " ")
+	}
+
+	return s
+}
+
+type statusJSON struct {
+	Mode              string     `json:"mode"`
+	Policy            string     `json:"policy"`
+	Throttling        bool       `json:"throttling"`
+	ThrottleRemaining string     `json:"throttle_remaining,omitempty"`
+	Sandboxes         int32      `json:"sandboxes"`
+	Current           *sysfsJSON `json:"current"`
+	Initial           *sysfsJSON `json:"initial"`
+}
+
+func newStatusJSON(s *kpb.StatusResponse) statusJSON {
+	status := statusJSON{
+		Mode:       s.Mode,
+		Policy:     s.Policy,
+		Throttling: s.Throttling,
+		Sandboxes:  s.Sandboxes,
+		Current:    newSysfsJSON(s.Current),
+		Initial:    newSysfsJSON(s.Initial),
+	}
+
+	if s.ThrottleRemaining != nil {
+		if remaining, err := ptypes.Duration(s.ThrottleRemaining); err == nil && remaining > 0 {
+			status.ThrottleRemaining = remaining.String()
+		}
+	}
+
+	return status
+}
+
+type statsJSON struct {
+	PagesShared      int64    `json:"pages_shared"`
+	PagesSharing     int64    `json:"pages_sharing"`
+	PagesUnshared    int64    `json:"pages_unshared"`
+	PagesVolatile    int64    `json:"pages_volatile"`
+	FullScans        int64    `json:"full_scans"`
+	StableNodeChains int64    `json:"stable_node_chains"`
+	GeneralProfit    int64    `json:"general_profit"`
+	SharingRatio     float64  `json:"sharing_ratio"`
+	BytesSaved       int64    `json:"bytes_saved"`
+	Unavailable      []string `json:"unavailable,omitempty"`
+}
+
+func newStatsJSON(s *kpb.Stats) statsJSON {
+	return statsJSON{
+		PagesShared:      s.PagesShared,
+		PagesSharing:     s.PagesSharing,
+		PagesUnshared:    s.PagesUnshared,
+		PagesVolatile:    s.PagesVolatile,
+		FullScans:        s.FullScans,
+		StableNodeChains: s.StableNodeChains,
+		GeneralProfit:    s.GeneralProfit,
+		SharingRatio:     s.SharingRatio,
+		BytesSaved:       s.BytesSaved,
+		Unavailable:      s.Unavailable,
+	}
+}
+
+type kickJSON struct {
+	SandboxID string `json:"sandbox_id,omitempty"`
+	Mode      string `json:"mode,omitempty"`
+	Hold      string `json:"hold,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type eventJSON struct {
+	Type    string     `json:"type"`
+	Time    string     `json:"time"`
+	OldMode string     `json:"old_mode,omitempty"`
+	NewMode string     `json:"new_mode,omitempty"`
+	Values  *sysfsJSON `json:"values,omitempty"`
+	Kick    *kickJSON  `json:"kick,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+func newEventJSON(e *kpb.Event) eventJSON {
+	event := eventJSON{
+		Type:    strings.ToLower(e.Type.String()),
+		OldMode: e.OldMode,
+		NewMode: e.NewMode,
+		Values:  newSysfsJSON(e.Values),
+		Error:   e.Error,
+	}
+
+	if t, err := ptypes.Timestamp(e.Time); err == nil {
+		event.Time = t.Format(time.RFC3339Nano)
+	}
+
+	if e.Kick != nil {
+		event.Kick = &kickJSON{
+			SandboxID: e.Kick.SandboxId,
+			Mode:      e.Kick.Mode,
+			Reason:    e.Kick.Reason,
+		}
+
+		if hold, err := ptypes.Duration(e.Kick.Hold); err == nil && e.Kick.Hold != nil {
+			event.Kick.Hold = hold.String()
+		}
+	}
+
+	return event
+}
+
+func (e eventJSON) String() string {
+	s := e.Time + " " + e.Type
+
+	switch {
+	case e.Kick != nil:
+		s += fmt.Sprintf(" mode=%s hold=%s", e.Kick.Mode, e.Kick.Hold)
+		if e.Kick.SandboxID != "" {
+			s += " sandbox=" + e.Kick.SandboxID
+		}
+		if e.Kick.Reason != "" {
+			s += fmt.Sprintf(" reason=%q", e.Kick.Reason)
+		}
+
+	case e.Error != "":
+		s += " " + e.Error
+
+	case e.OldMode != "":
+		s += fmt.Sprintf(" %s -> %s %s", e.OldMode, e.NewMode, e.Values)
+
+	default:
+		s += " " + e.Values.String()
+	}
+
+	return s
+}
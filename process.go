@@ -0,0 +1,83 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// processStats holds the KSM counters of a process, from
+// /proc/<pid>/ksm_stat.
+type processStats struct {
+	pid           int
+	mergingPages  int64
+	rmapItems     int64
+	zeroPages     int64
+	processProfit int64
+}
+
+// readProcessStats reads the KSM counters of a process. Counters the
+// running kernel does not export are left to zero.
+func readProcessStats(pid int) (processStats, error) {
+	s := processStats{pid: pid}
+
+	f, err := os.Open(filepath.Join(procRoot, strconv.Itoa(pid), "ksm_stat"))
+	if err != nil {
+		return s, err
+	}
+	defer f.Close()
+
+	counters := map[string]*int64{
+		"ksm_merging_pages":  &s.mergingPages,
+		"ksm_rmap_items":     &s.rmapItems,
+		"ksm_zero_pages":     &s.zeroPages,
+		"ksm_process_profit": &s.processProfit,
+	}
+
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		// e.g. "ksm_merging_pages 1234"
+		fields := strings.Fields(scan.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		value, ok := counters[fields[0]]
+		if !ok {
+			continue
+		}
+
+		*value, err = strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return s, fmt.Errorf("Invalid %s for PID %d", fields[0], pid)
+		}
+	}
+
+	return s, scan.Err()
+}
+
+// processStats returns the KSM counters of a process.
+func (k *ksm) processStats(pid int) (processStats, error) {
+	k.Lock()
+	initialized := k.initialized
+	k.Unlock()
+
+	if !initialized {
+		return processStats{}, errKSMUnavailable
+	}
+
+	if pid <= 0 {
+		return processStats{}, fmt.Errorf("Invalid PID %d", pid)
+	}
+
+	return readProcessStats(pid)
+}
@@ -0,0 +1,99 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const vmmPid = 1
+
+func writeTestProcFile(t *testing.T, pid int, name, content string) {
+	err := ioutil.WriteFile(filepath.Join(procRoot, fmt.Sprintf("%d", pid), name), []byte(content), 0644)
+	assert.Nil(t, err)
+}
+
+func TestReadProcessStats(t *testing.T) {
+	cleanup := setTestProc(t)
+	defer cleanup()
+
+	writeTestProcFile(t, vmmPid, "ksm_stat", `ksm_rmap_items 4096
+ksm_zero_pages 12
+ksm_merging_pages 1024
+ksm_process_profit 3932160
+ksm_merge_any: no
+ksm_mergeable: yes
+`)
+
+	s, err := readProcessStats(vmmPid)
+	assert.Nil(t, err)
+	assert.Equal(t, processStats{
+		pid:           vmmPid,
+		mergingPages:  1024,
+		rmapItems:     4096,
+		zeroPages:     12,
+		processProfit: 3932160,
+	}, s)
+
+	// Older kernels only export some of the counters
+	writeTestProcFile(t, vmmPid, "ksm_stat", "ksm_rmap_items 10\n")
+
+	s, err = readProcessStats(vmmPid)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), s.rmapItems)
+	assert.Equal(t, int64(0), s.mergingPages)
+
+	writeTestProcFile(t, vmmPid, "ksm_stat", "ksm_rmap_items many\n")
+
+	_, err = readProcessStats(vmmPid)
+	assert.NotNil(t, err)
+
+	_, err = readProcessStats(ksmdPid)
+	assert.NotNil(t, err)
+}
+
+func TestReadProcessStatsLive(t *testing.T) {
+	// Read the test binary counters, in the kernel format
+	pid := os.Getpid()
+
+	if _, err := os.Stat(filepath.Join(procRoot, strconv.Itoa(pid), "ksm_stat")); os.IsNotExist(err) {
+		t.Skip("The running kernel does not export the processes KSM counters")
+	}
+
+	s, err := readProcessStats(pid)
+	assert.Nil(t, err)
+	assert.Equal(t, pid, s.pid)
+	assert.True(t, s.mergingPages >= 0)
+	assert.True(t, s.rmapItems >= 0)
+}
+
+func TestKSMProcessStats(t *testing.T) {
+	k := initKSM(defaultKSMRoot, t)
+	defer k.restore()
+
+	_, err := k.processStats(0)
+	assert.NotNil(t, err)
+
+	cleanup := setTestProc(t)
+	defer cleanup()
+
+	writeTestProcFile(t, vmmPid, "ksm_stat", "ksm_merging_pages 20\n")
+
+	s, err := k.processStats(vmmPid)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(20), s.mergingPages)
+
+	_, err = k.processStats(ksmdPid)
+	assert.NotNil(t, err)
+}
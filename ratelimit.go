@@ -0,0 +1,186 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// kickRate is how many kicks per second a client can send in the long
+// run, and kickBurst how many it can send at once. A zero kickRate
+// disables the rate limit.
+var kickRate = 50.0
+var kickBurst = 200
+
+// kickLimiterMaxClients is how many clients we track before forgetting
+// the ones that are back to a full bucket.
+const kickLimiterMaxClients = 1024
+
+var errKickRateLimited = errors.New("Kick rate limit exceeded")
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// kickLimiter rate limits the kicks of each client, with a token bucket
+// per client.
+type kickLimiter struct {
+	sync.Mutex
+
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+func newKickLimiter(rate float64, burst int) *kickLimiter {
+	return &kickLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// refill is unlocked. You should take the limiter lock before calling it.
+func (l *kickLimiter) refill(b *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rate)
+	}
+
+	b.last = now
+}
+
+// allow tells whether a client is within its rate limit, and takes a
+// kick from its bucket if so. A nil limiter allows all kicks.
+func (l *kickLimiter) allow(client string, now time.Time) bool {
+	if l == nil || l.rate <= 0 {
+		return true
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if len(l.buckets) >= kickLimiterMaxClients {
+		for c, b := range l.buckets {
+			if l.refill(b, now); b.tokens >= l.burst {
+				delete(l.buckets, c)
+			}
+		}
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+
+	l.refill(b, now)
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
+
+// peerCredInfo holds the credentials of a UNIX socket client.
+type peerCredInfo struct {
+	uid uint32
+	pid int32
+}
+
+func (peerCredInfo) AuthType() string {
+	return "peercred"
+}
+
+// peerCredentials identifies our clients from their UNIX socket
+// credentials. It does not authenticate them, the socket permissions
+// do.
+type peerCredentials struct{}
+
+func (peerCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return conn, nil, nil
+}
+
+func (peerCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return conn, nil, nil
+	}
+
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		throttlerLog.WithError(err).Debug("Could not get client credentials")
+		return conn, nil, nil
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err == nil {
+		err = credErr
+	}
+
+	if err != nil {
+		throttlerLog.WithError(err).Debug("Could not get client credentials")
+		return conn, nil, nil
+	}
+
+	return conn, peerCredInfo{uid: cred.Uid, pid: cred.Pid}, nil
+}
+
+func (peerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "peercred"}
+}
+
+func (peerCredentials) Clone() credentials.TransportCredentials {
+	return peerCredentials{}
+}
+
+func (peerCredentials) OverrideServerName(string) error {
+	return nil
+}
+
+// kickClient identifies the client of a call, for rate limiting, from
+// its credentials: Kicks carry a sandbox ID of the client choice. All
+// our clients usually run as root, so the user ID only identifies the
+// clients whose PID we do not know.
+func kickClient(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if info, ok := p.AuthInfo.(peerCredInfo); ok {
+		if info.pid > 0 {
+			return fmt.Sprintf("pid:%d", info.pid)
+		}
+
+		return fmt.Sprintf("uid:%d", info.uid)
+	}
+
+	if p.Addr == nil {
+		return ""
+	}
+
+	return p.Addr.String()
+}
@@ -0,0 +1,103 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/peer"
+)
+
+func TestKickLimiter(t *testing.T) {
+	l := newKickLimiter(10, 2)
+	now := time.Now()
+
+	// The burst goes through, and then the rate
+	assert.True(t, l.allow("a", now))
+	assert.True(t, l.allow("a", now))
+	assert.False(t, l.allow("a", now))
+
+	// Clients have their own bucket
+	assert.True(t, l.allow("b", now))
+
+	now = now.Add(100 * time.Millisecond)
+	assert.True(t, l.allow("a", now))
+	assert.False(t, l.allow("a", now))
+
+	// Buckets never hold more than the burst
+	now = now.Add(time.Hour)
+	assert.True(t, l.allow("a", now))
+	assert.True(t, l.allow("a", now))
+	assert.False(t, l.allow("a", now))
+}
+
+func TestKickLimiterDisabled(t *testing.T) {
+	var l *kickLimiter
+	assert.True(t, l.allow("a", time.Now()))
+
+	l = newKickLimiter(0, 0)
+	for i := 0; i < 10; i++ {
+		assert.True(t, l.allow("a", time.Now()))
+	}
+}
+
+func TestKickLimiterForget(t *testing.T) {
+	l := newKickLimiter(1, 1)
+	now := time.Now()
+
+	for i := 0; i < kickLimiterMaxClients; i++ {
+		assert.True(t, l.allow(fmt.Sprintf("client-%d", i), now))
+	}
+	assert.Equal(t, kickLimiterMaxClients, len(l.buckets))
+
+	// Full buckets are forgotten
+	now = now.Add(time.Second)
+	assert.True(t, l.allow("new", now))
+	assert.Equal(t, 1, len(l.buckets))
+}
+
+func TestPeerCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ksmthrottler-peercred")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	listener, err := net.Listen("unix", filepath.Join(dir, "test.sock"))
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	client, err := net.Dial("unix", filepath.Join(dir, "test.sock"))
+	assert.Nil(t, err)
+	defer client.Close()
+
+	conn, err := listener.Accept()
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	_, info, err := peerCredentials{}.ServerHandshake(conn)
+	assert.Nil(t, err)
+
+	cred, ok := info.(peerCredInfo)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(os.Getuid()), cred.uid)
+	assert.Equal(t, int32(os.Getpid()), cred.pid)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: conn.RemoteAddr(), AuthInfo: info})
+	assert.Equal(t, fmt.Sprintf("pid:%d", os.Getpid()), kickClient(ctx))
+
+	ctx = peer.NewContext(context.Background(), &peer.Peer{AuthInfo: peerCredInfo{uid: 1000}})
+	assert.Equal(t, "uid:1000", kickClient(ctx))
+
+	assert.Equal(t, "", kickClient(context.Background()))
+}
@@ -0,0 +1,202 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+var errMissingSandboxID = errors.New("Sandbox PIDs require a sandbox ID")
+
+var errReleaseSandboxID = errors.New("Releasing a sandbox requires its ID")
+
+// sandboxStats holds the KSM savings of a sandbox processes.
+type sandboxStats struct {
+	id        string
+	processes []processStats
+
+	// mergingPages and processProfit are the sums of the sandbox
+	// processes counters.
+	mergingPages  int64
+	processProfit int64
+}
+
+// checkSandbox validates the sandbox a kick registers.
+func checkSandbox(id string, pids []int) error {
+	if id == "" {
+		if len(pids) > 0 {
+			return errMissingSandboxID
+		}
+
+		return nil
+	}
+
+	for _, pid := range pids {
+		if pid <= 0 {
+			return fmt.Errorf("Invalid PID %d for sandbox %s", pid, id)
+		}
+	}
+
+	return nil
+}
+
+// registerSandbox adds pids to the processes of a sandbox, for its KSM
+// savings to be accounted.
+func (k *ksm) registerSandbox(id string, pids []int) error {
+	if err := checkSandbox(id, pids); err != nil {
+		return err
+	}
+
+	if id == "" {
+		return nil
+	}
+
+	k.Lock()
+	defer k.Unlock()
+
+	registered := k.sandboxes[id]
+
+	for _, pid := range pids {
+		known := false
+		for _, p := range registered {
+			if p == pid {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			registered = append(registered, pid)
+		}
+	}
+
+	if len(registered) > 0 {
+		k.sandboxes[id] = registered
+	}
+
+	throttlerLog.WithFields(logrus.Fields{
+		"sandbox": id,
+		"pids":    registered,
+	}).Debug("Sandbox registered")
+
+	return nil
+}
+
+// kickSandbox registers a sandbox and then throttles KSM up.
+func (k *ksm) kickSandbox(id string, pids []int, kick ksmKick) error {
+	if err := k.registerSandbox(id, pids); err != nil {
+		return err
+	}
+
+	kick.sandbox = id
+	k.kickWith(kick)
+
+	return nil
+}
+
+// releaseSandbox removes pids from the processes of a sandbox, or the
+// whole sandbox when pids is empty. Sandboxes with no process left are
+// forgotten.
+func (k *ksm) releaseSandbox(id string, pids []int) error {
+	if id == "" {
+		return errReleaseSandboxID
+	}
+
+	k.Lock()
+	defer k.Unlock()
+
+	registered, ok := k.sandboxes[id]
+	if !ok {
+		return nil
+	}
+
+	var left []int
+	if len(pids) > 0 {
+		for _, p := range registered {
+			released := false
+			for _, pid := range pids {
+				if p == pid {
+					released = true
+					break
+				}
+			}
+
+			if !released {
+				left = append(left, p)
+			}
+		}
+	}
+
+	if len(left) == 0 {
+		delete(k.sandboxes, id)
+	} else {
+		k.sandboxes[id] = left
+	}
+
+	throttlerLog.WithFields(logrus.Fields{
+		"sandbox": id,
+		"pids":    left,
+	}).Debug("Sandbox released")
+
+	return nil
+}
+
+// listSandboxes returns the KSM savings of the registered sandboxes,
+// sorted by ID. Processes that exited are forgotten, and so are the
+// sandboxes with no process left.
+func (k *ksm) listSandboxes() ([]sandboxStats, error) {
+	k.Lock()
+	defer k.Unlock()
+
+	if !k.initialized {
+		return nil, errKSMUnavailable
+	}
+
+	var sandboxes []sandboxStats
+
+	for id, pids := range k.sandboxes {
+		s := sandboxStats{id: id}
+		var alive []int
+
+		for _, pid := range pids {
+			p, err := readProcessStats(pid)
+			if os.IsNotExist(err) {
+				throttlerLog.WithFields(logrus.Fields{
+					"sandbox": id,
+					"pid":     pid,
+				}).Debug("Sandbox process exited")
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			alive = append(alive, pid)
+			s.processes = append(s.processes, p)
+			s.mergingPages += p.mergingPages
+			s.processProfit += p.processProfit
+		}
+
+		if len(alive) == 0 {
+			delete(k.sandboxes, id)
+			continue
+		}
+
+		k.sandboxes[id] = alive
+		sandboxes = append(sandboxes, s)
+	}
+
+	sort.Slice(sandboxes, func(i, j int) bool {
+		return sandboxes[i].id < sandboxes[j].id
+	})
+
+	return sandboxes, nil
+}
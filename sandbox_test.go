@@ -0,0 +1,124 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestProcessStats(t *testing.T, pid int, mergingPages, profit int64) {
+	err := os.MkdirAll(filepath.Join(procRoot, fmt.Sprintf("%d", pid)), 0755)
+	assert.Nil(t, err)
+
+	writeTestProcFile(t, pid, "ksm_stat", fmt.Sprintf(
+		"ksm_rmap_items %d\nksm_merging_pages %d\nksm_process_profit %d\n",
+		2*mergingPages, mergingPages, profit))
+}
+
+func TestKSMSandboxes(t *testing.T) {
+	cleanup := setTestProc(t)
+	defer cleanup()
+
+	k := initKSM(defaultKSMRoot, t)
+	defer k.restore()
+
+	writeTestProcessStats(t, 100, 1000, 4000000)
+	writeTestProcessStats(t, 101, 10, 40000)
+	writeTestProcessStats(t, 200, 500, 2000000)
+
+	err := k.kickSandbox("sandbox-b", []int{200}, ksmKick{})
+	assert.Nil(t, err)
+
+	err = k.kickSandbox("sandbox-a", []int{100}, ksmKick{})
+	assert.Nil(t, err)
+
+	// Kicking again adds processes
+	err = k.kickSandbox("sandbox-a", []int{100, 101}, ksmKick{})
+	assert.Nil(t, err)
+
+	// Anonymous kicks are not accounted
+	err = k.kickSandbox("", nil, ksmKick{})
+	assert.Nil(t, err)
+
+	sandboxes, err := k.listSandboxes()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(sandboxes))
+
+	assert.Equal(t, "sandbox-a", sandboxes[0].id)
+	assert.Equal(t, int64(1010), sandboxes[0].mergingPages)
+	assert.Equal(t, int64(4040000), sandboxes[0].processProfit)
+	assert.Equal(t, 2, len(sandboxes[0].processes))
+
+	assert.Equal(t, "sandbox-b", sandboxes[1].id)
+	assert.Equal(t, int64(500), sandboxes[1].mergingPages)
+	assert.Equal(t, int64(1000), sandboxes[1].processes[0].rmapItems)
+
+	// Exited processes and sandboxes are forgotten
+	os.RemoveAll(filepath.Join(procRoot, "101"))
+	os.RemoveAll(filepath.Join(procRoot, "200"))
+
+	sandboxes, err = k.listSandboxes()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(sandboxes))
+	assert.Equal(t, int64(1000), sandboxes[0].mergingPages)
+	assert.Equal(t, []int{100}, k.sandboxes["sandbox-a"])
+}
+
+func TestKSMSandboxInvalid(t *testing.T) {
+	k := initKSM(defaultKSMRoot, t)
+	defer k.restore()
+
+	err := k.kickSandbox("", []int{100}, ksmKick{})
+	assert.Equal(t, errMissingSandboxID, err)
+
+	err = k.kickSandbox("sandbox", []int{-1}, ksmKick{})
+	assert.NotNil(t, err)
+
+	assert.Equal(t, 0, len(k.sandboxes))
+}
+
+func TestKSMSandboxRelease(t *testing.T) {
+	k := initKSM(defaultKSMRoot, t)
+	defer k.restore()
+
+	err := k.registerSandbox("sandbox-a", []int{100, 101})
+	assert.Nil(t, err)
+
+	err = k.registerSandbox("sandbox-b", []int{200})
+	assert.Nil(t, err)
+
+	s, err := k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, s.sandboxes)
+
+	err = k.releaseSandbox("sandbox-a", []int{101})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{100}, k.sandboxes["sandbox-a"])
+
+	// Releasing the last process releases the sandbox
+	err = k.releaseSandbox("sandbox-a", []int{100})
+	assert.Nil(t, err)
+
+	err = k.releaseSandbox("sandbox-b", nil)
+	assert.Nil(t, err)
+
+	s, err = k.status()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, s.sandboxes)
+
+	// Unknown sandboxes are ignored, anonymous ones are invalid
+	err = k.releaseSandbox("sandbox-c", nil)
+	assert.Nil(t, err)
+
+	err = k.releaseSandbox("", []int{100})
+	assert.Equal(t, errReleaseSandboxID, err)
+}
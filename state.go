@@ -0,0 +1,225 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ksmStateFile is where we persist the initial KSM settings. When set,
+// a restarted throttler restores the settings it found when first
+// started, instead of the ones it left behind, e.g. when it got killed
+// while throttling KSM up.
+var ksmStateFile = ""
+
+// bootIDPath identifies the current boot: The initial KSM settings are
+// captured again after a reboot.
+var bootIDPath = "/proc/sys/kernel/random/boot_id"
+
+var errKSMNotInitial = errors.New("KSM does not run its initial settings")
+
+// ksmState is the state file content.
+type ksmState struct {
+	BootID         string            `json:"boot_id"`
+	Root           string            `json:"root"`
+	Run            string            `json:"run"`
+	PagesToScan    string            `json:"pages_to_scan"`
+	SleepMillisecs string            `json:"sleep_millisecs"`
+	Knobs          map[string]string `json:"knobs,omitempty"`
+}
+
+func bootID() string {
+	data, err := ioutil.ReadFile(bootIDPath)
+	if err != nil {
+		throttlerLog.WithError(err).Debug("Could not read boot ID")
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func readState(path string) (*ksmState, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s ksmState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// writeState replaces the state file atomically, for a crash not to
+// leave a truncated one behind.
+func writeState(path string, s *ksmState) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// removeState removes the state file once we restored the initial
+// settings: Only a throttler restarted after a crash reuses them, a
+// cleanly restarted one captures them again.
+func removeState(path string) {
+	if path == "" {
+		return
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		throttlerLog.WithError(err).WithField("state-file", path).Error("Could not remove the KSM state file")
+	}
+}
+
+// newKSMState returns the state persisting KSM values for the current
+// boot.
+func newKSMState(root string, v ksmValues) *ksmState {
+	return &ksmState{
+		BootID:         bootID(),
+		Root:           root,
+		Run:            v.run,
+		PagesToScan:    v.pagesToScan,
+		SleepMillisecs: v.sleepInterval,
+		Knobs:          v.knobs,
+	}
+}
+
+// setInitialState is unlocked. You should take the ksm lock before
+// calling it.
+func (k *ksm) setInitialState(s *ksmState) {
+	k.initialKSMRun = s.Run
+	k.initialPagesToScan = s.PagesToScan
+	k.initialSleepInterval = s.SleepMillisecs
+
+	for name, value := range s.Knobs {
+		if knob, ok := k.knobs[name]; ok {
+			knob.initial = value
+		}
+	}
+}
+
+// loadState replaces the initial KSM settings we just captured with the
+// persisted ones. When the state file is missing, or was written for
+// another boot or KSM root, it persists the captured settings instead.
+func (k *ksm) loadState(path string) {
+	logger := throttlerLog.WithField("state-file", path)
+
+	captured := newKSMState(k.root, ksmValues{
+		run:           k.initialKSMRun,
+		pagesToScan:   k.initialPagesToScan,
+		sleepInterval: k.initialSleepInterval,
+		knobs:         k.initialKnobs(),
+	}.trim())
+
+	s, err := readState(path)
+	switch {
+	case os.IsNotExist(err):
+		logger.Debug("No KSM state file, saving the initial KSM settings")
+
+	case err != nil:
+		logger.WithError(err).Error("Invalid KSM state file, saving the current KSM settings")
+
+	case s.BootID != captured.BootID || s.Root != captured.Root:
+		logger.WithFields(logrus.Fields{
+			"boot-id":  s.BootID,
+			"ksm-root": s.Root,
+		}).Info("Stale KSM state file, saving the current KSM settings")
+
+	default:
+		logger.Debug("Restoring the persisted initial KSM settings")
+		k.setInitialState(s)
+		return
+	}
+
+	if err := writeState(path, captured); err != nil {
+		logger.WithError(err).Error("Could not save the initial KSM settings")
+	}
+}
+
+// rebaseline makes the current KSM settings the initial ones, the ones
+// restored when we stop throttling. KSM must run its initial settings:
+// We would otherwise capture our own ones.
+func (k *ksm) rebaseline() error {
+	k.modeLock.Lock()
+	defer k.modeLock.Unlock()
+
+	k.Lock()
+	defer k.Unlock()
+
+	if !k.initialized {
+		return errKSMUnavailable
+	}
+
+	if k.currentKnob != ksmInitial {
+		return errKSMNotInitial
+	}
+
+	current, err := k.currentValues()
+	if err != nil {
+		return err
+	}
+
+	s := newKSMState(k.root, current)
+
+	if ksmStateFile != "" {
+		if err := writeState(ksmStateFile, s); err != nil {
+			return err
+		}
+	}
+
+	k.setInitialState(s)
+
+	throttlerLog.WithFields(logrus.Fields{
+		"run":             s.Run,
+		"pages_to_scan":   s.PagesToScan,
+		"sleep_millisecs": s.SleepMillisecs,
+	}).Info("New initial KSM settings")
+
+	return nil
+}
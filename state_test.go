@@ -0,0 +1,176 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setTestState points the state and boot ID files to a temporary
+// directory.
+func setTestState(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "ksmthrottler-state")
+	assert.Nil(t, err)
+
+	savedStateFile := ksmStateFile
+	savedBootIDPath := bootIDPath
+
+	ksmStateFile = filepath.Join(dir, "lib", "state.json")
+	bootIDPath = filepath.Join(dir, "boot_id")
+	setTestBootID(t, "boot-1")
+
+	return func() {
+		ksmStateFile = savedStateFile
+		bootIDPath = savedBootIDPath
+		os.RemoveAll(dir)
+	}
+}
+
+func setTestBootID(t *testing.T, id string) {
+	err := ioutil.WriteFile(bootIDPath, []byte(id+"\n"), 0644)
+	assert.Nil(t, err)
+}
+
+func writeTestValues(t *testing.T, k *ksm, run, pagesToScan, sleepInterval string) {
+	assert.Nil(t, k.run.write(run))
+	assert.Nil(t, k.pagesToScan.write(pagesToScan))
+	assert.Nil(t, k.sleepInterval.write(sleepInterval))
+}
+
+func initialValues(t *testing.T, k *ksm) ksmValues {
+	s, err := k.status()
+	assert.Nil(t, err)
+
+	return s.initial
+}
+
+func TestKSMState(t *testing.T) {
+	k := initKSM(defaultKSMRoot, t)
+	writeTestValues(t, k, "1", "100", "20")
+
+	cleanup := setTestState(t)
+	defer cleanup()
+
+	// The first start persists the initial settings
+	k = initKSM(defaultKSMRoot, t)
+
+	s, err := readState(ksmStateFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "boot-1", s.BootID)
+	assert.Equal(t, defaultKSMRoot, s.Root)
+	assert.Equal(t, "100", s.PagesToScan)
+	assert.Equal(t, "20", s.SleepMillisecs)
+
+	// Restarting while throttling restores them
+	writeTestValues(t, k, "1", "5000", "1")
+
+	k = initKSM(defaultKSMRoot, t)
+	initial := initialValues(t, k)
+	assert.Equal(t, "100", initial.pagesToScan)
+	assert.Equal(t, "20", initial.sleepInterval)
+
+	err = k.restore()
+	assert.Nil(t, err)
+	assert.Equal(t, "100", readTestKnob(t, ksmPagesToScan))
+
+	_, err = os.Stat(ksmStateFile)
+	assert.True(t, os.IsNotExist(err))
+
+	// After a reboot, the settings are captured again
+	k = initKSM(defaultKSMRoot, t)
+	writeTestValues(t, k, "0", "300", "50")
+	setTestBootID(t, "boot-2")
+
+	k = initKSM(defaultKSMRoot, t)
+	initial = initialValues(t, k)
+	assert.Equal(t, "300", initial.pagesToScan)
+
+	s, err = readState(ksmStateFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "boot-2", s.BootID)
+	assert.Equal(t, "300", s.PagesToScan)
+}
+
+func TestKSMStateCleanShutdown(t *testing.T) {
+	k := initKSM(defaultKSMRoot, t)
+	writeTestValues(t, k, "1", "100", "20")
+
+	cleanup := setTestState(t)
+	defer cleanup()
+
+	k = initKSM(defaultKSMRoot, t)
+	writeTestValues(t, k, "1", "5000", "1")
+
+	err := k.restore()
+	assert.Nil(t, err)
+
+	// The settings changed while we were stopped, on the same boot:
+	// A clean restart captures them instead of the stale ones.
+	err = ioutil.WriteFile(filepath.Join(defaultKSMRoot, ksmPagesToScan), []byte("700\n"), 0644)
+	assert.Nil(t, err)
+
+	k = initKSM(defaultKSMRoot, t)
+	assert.Equal(t, "700", initialValues(t, k).pagesToScan)
+
+	s, err := readState(ksmStateFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "700", s.PagesToScan)
+}
+
+func TestKSMInvalidState(t *testing.T) {
+	k := initKSM(defaultKSMRoot, t)
+	writeTestValues(t, k, "1", "400", "40")
+
+	cleanup := setTestState(t)
+	defer cleanup()
+
+	err := os.MkdirAll(filepath.Dir(ksmStateFile), 0755)
+	assert.Nil(t, err)
+
+	err = ioutil.WriteFile(ksmStateFile, []byte("{"), 0644)
+	assert.Nil(t, err)
+
+	k = initKSM(defaultKSMRoot, t)
+	assert.Equal(t, "400", initialValues(t, k).pagesToScan)
+
+	s, err := readState(ksmStateFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "400", s.PagesToScan)
+}
+
+func TestKSMRebaseline(t *testing.T) {
+	cleanup := setTestState(t)
+	defer cleanup()
+
+	k := initKSM(defaultKSMRoot, t)
+	writeTestValues(t, k, "1", "600", "60")
+
+	err := k.rebaseline()
+	assert.Nil(t, err)
+
+	initial := initialValues(t, k)
+	assert.Equal(t, "1", initial.run)
+	assert.Equal(t, "600", initial.pagesToScan)
+	assert.Equal(t, "60", initial.sleepInterval)
+
+	s, err := readState(ksmStateFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "600", s.PagesToScan)
+
+	// We do not re-baseline our own settings
+	err = k.moveTo(ksmSlow)
+	assert.Nil(t, err)
+
+	err = k.rebaseline()
+	assert.Equal(t, errKSMNotInitial, err)
+	assert.Equal(t, "600", initialValues(t, k).pagesToScan)
+}
@@ -0,0 +1,121 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	ksmPagesShared      = "pages_shared"
+	ksmPagesSharing     = "pages_sharing"
+	ksmPagesUnshared    = "pages_unshared"
+	ksmPagesVolatile    = "pages_volatile"
+	ksmFullScans        = "full_scans"
+	ksmStableNodeChains = "stable_node_chains"
+	ksmGeneralProfit    = "general_profit"
+)
+
+// ksmStats holds the KSM merging counters exported through sysfs.
+type ksmStats struct {
+	pagesShared      int64
+	pagesSharing     int64
+	pagesUnshared    int64
+	pagesVolatile    int64
+	fullScans        int64
+	stableNodeChains int64
+	generalProfit    int64
+
+	// unavailable lists the optional counters the running
+	// kernel does not export.
+	unavailable []string
+}
+
+type ksmCounter struct {
+	name     string
+	value    *int64
+	optional bool
+}
+
+func (s *ksmStats) counters() []ksmCounter {
+	return []ksmCounter{
+		{ksmPagesShared, &s.pagesShared, false},
+		{ksmPagesSharing, &s.pagesSharing, false},
+		{ksmPagesUnshared, &s.pagesUnshared, false},
+		{ksmPagesVolatile, &s.pagesVolatile, false},
+		{ksmFullScans, &s.fullScans, false},
+		// Only exported by recent kernels
+		{ksmStableNodeChains, &s.stableNodeChains, true},
+		{ksmGeneralProfit, &s.generalProfit, true},
+	}
+}
+
+// available tells if the running kernel exports the given counter.
+func (s ksmStats) available(name string) bool {
+	for _, n := range s.unavailable {
+		if n == name {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sharingRatio tells how many sites share each KSM page.
+// Higher values mean more efficient merging.
+func (s ksmStats) sharingRatio() float64 {
+	if s.pagesShared == 0 {
+		return 0
+	}
+
+	return float64(s.pagesSharing) / float64(s.pagesShared)
+}
+
+// bytesSaved is the amount of memory saved by KSM.
+func (s ksmStats) bytesSaved() int64 {
+	return s.pagesSharing * int64(os.Getpagesize())
+}
+
+func readKSMCounter(root, name string) (int64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func (k *ksm) stats() (ksmStats, error) {
+	var s ksmStats
+
+	k.Lock()
+	defer k.Unlock()
+
+	if !k.initialized {
+		return s, errKSMUnavailable
+	}
+
+	for _, c := range s.counters() {
+		value, err := readKSMCounter(k.root, c.name)
+		if err != nil {
+			if c.optional && os.IsNotExist(err) {
+				s.unavailable = append(s.unavailable, c.name)
+				continue
+			}
+
+			return s, err
+		}
+
+		*c.value = value
+	}
+
+	return s, nil
+}
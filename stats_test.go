@@ -0,0 +1,83 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeKSMCounters(counters map[string]string) error {
+	for name, value := range counters {
+		err := ioutil.WriteFile(filepath.Join(defaultKSMRoot, name), []byte(value+"\n"), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func removeKSMCounters(counters map[string]string) {
+	for name := range counters {
+		os.Remove(filepath.Join(defaultKSMRoot, name))
+	}
+}
+
+func TestKSMStats(t *testing.T) {
+	counters := map[string]string{
+		ksmPagesShared:   "100",
+		ksmPagesSharing:  "400",
+		ksmPagesUnshared: "50",
+		ksmPagesVolatile: "10",
+		ksmFullScans:     "3",
+	}
+
+	err := writeKSMCounters(counters)
+	defer removeKSMCounters(counters)
+	assert.Nil(t, err)
+
+	k := initKSM(defaultKSMRoot, t)
+	defer k.restore()
+
+	s, err := k.stats()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), s.pagesShared)
+	assert.Equal(t, int64(400), s.pagesSharing)
+	assert.Equal(t, int64(50), s.pagesUnshared)
+	assert.Equal(t, int64(10), s.pagesVolatile)
+	assert.Equal(t, int64(3), s.fullScans)
+	assert.Equal(t, []string{ksmStableNodeChains, ksmGeneralProfit}, s.unavailable)
+	assert.Equal(t, 4.0, s.sharingRatio())
+	assert.Equal(t, 400*int64(os.Getpagesize()), s.bytesSaved())
+}
+
+func TestKSMStatsMissingCounter(t *testing.T) {
+	counters := map[string]string{
+		ksmPagesShared: "100",
+	}
+
+	err := writeKSMCounters(counters)
+	defer removeKSMCounters(counters)
+	assert.Nil(t, err)
+
+	k := initKSM(defaultKSMRoot, t)
+	defer k.restore()
+
+	_, err = k.stats()
+	assert.NotNil(t, err)
+}
+
+func TestKSMStatsSharingRatio(t *testing.T) {
+	var s ksmStats
+
+	assert.Equal(t, 0.0, s.sharingRatio())
+}
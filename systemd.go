@@ -0,0 +1,166 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// listenFdsStart is the first file descriptor passed by systemd,
+	// see sd_listen_fds(3).
+	listenFdsStart = 3
+
+	sdReady     = "READY=1"
+	sdStopping  = "STOPPING=1"
+	sdWatchdog  = "WATCHDOG=1"
+	sdStatusFmt = "STATUS=KSM mode %s, %s policy"
+)
+
+// activationListener returns the socket systemd passed to us when
+// socket activated, or nil if we're not socket activated.
+func activationListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	nFds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nFds == 0 {
+		return nil, nil
+	}
+
+	// Our children must not think they're socket activated.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	if nFds != 1 {
+		return nil, fmt.Errorf("Expected 1 socket activated file descriptor, got %d", nFds)
+	}
+
+	syscall.CloseOnExec(listenFdsStart)
+
+	file := os.NewFile(listenFdsStart, "LISTEN_FD_3")
+	defer file.Close()
+
+	listen, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid socket activated file descriptor %v", err)
+	}
+
+	return listen, nil
+}
+
+// sdNotify sends state to the systemd notification socket, if any.
+// See sd_notify(3).
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	// Abstract socket
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+
+	return err
+}
+
+// watchdogInterval returns how often we should ping the systemd
+// watchdog, or 0 if it's not enabled for us. See sd_watchdog_enabled(3).
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	if pidStr := os.Getenv("WATCHDOG_PID"); pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil || pid != os.Getpid() {
+			return 0
+		}
+	}
+
+	// systemd recommends pinging the watchdog every half timeout.
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+func sdStatus(k *ksm) string {
+	if k == nil {
+		return fmt.Sprintf("STATUS=%v", errKSMMissing)
+	}
+
+	s, err := k.status()
+	if err != nil {
+		return fmt.Sprintf("STATUS=%v", err)
+	}
+
+	return fmt.Sprintf(sdStatusFmt, s.knob, s.policy)
+}
+
+// notifySystemd tells systemd we're ready and keeps our status and
+// watchdog up to date, until ctx is done.
+func notifySystemd(ctx context.Context, k *ksm) {
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return
+	}
+
+	logger := throttlerLog.WithField("notify-socket", os.Getenv("NOTIFY_SOCKET"))
+
+	notify := func(state string) {
+		if err := sdNotify(state); err != nil {
+			logger.WithError(err).WithField("state", state).Error("could not notify systemd")
+		}
+	}
+
+	notify(sdReady + "\n" + sdStatus(k))
+
+	var knobChanged <-chan struct{}
+	if k != nil {
+		knobChanged = k.knobChanged
+	}
+
+	var watchdog <-chan time.Time
+	if interval := watchdogInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			notify(sdStopping)
+			return
+
+		case <-knobChanged:
+			notify(sdStatus(k))
+
+		case <-watchdog:
+			// Getting our status takes the ksm lock, we
+			// won't ping the watchdog if we're stuck.
+			notify(sdWatchdog + "\n" + sdStatus(k))
+		}
+	}
+}
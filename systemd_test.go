@@ -0,0 +1,171 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+// fakeNotifySocket creates a notification socket and points
+// NOTIFY_SOCKET to it.
+func fakeNotifySocket(t *testing.T) (*net.UnixConn, func()) {
+	dir, err := ioutil.TempDir("", "ksmthrottler-notify")
+	assert.Nil(t, err)
+
+	path := filepath.Join(dir, "notify.sock")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.Nil(t, err)
+
+	os.Setenv("NOTIFY_SOCKET", path)
+
+	return conn, func() {
+		os.Unsetenv("NOTIFY_SOCKET")
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func readNotification(t *testing.T, conn *net.UnixConn) string {
+	buf := make([]byte, 4096)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	assert.Nil(t, err)
+
+	return string(buf[:n])
+}
+
+func TestSdNotify(t *testing.T) {
+	os.Unsetenv("NOTIFY_SOCKET")
+
+	// Not started by systemd
+	err := sdNotify(sdReady)
+	assert.Nil(t, err)
+
+	conn, cleanup := fakeNotifySocket(t)
+	defer cleanup()
+
+	err = sdNotify(sdReady)
+	assert.Nil(t, err)
+	assert.Equal(t, sdReady, readNotification(t, conn))
+
+	os.Setenv("NOTIFY_SOCKET", "/nonexistent/notify.sock")
+	err = sdNotify(sdReady)
+	assert.NotNil(t, err)
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+
+	os.Unsetenv("WATCHDOG_USEC")
+	assert.Equal(t, time.Duration(0), watchdogInterval())
+
+	os.Setenv("WATCHDOG_USEC", "foo")
+	assert.Equal(t, time.Duration(0), watchdogInterval())
+
+	os.Setenv("WATCHDOG_USEC", "2000000")
+	assert.Equal(t, time.Second, watchdogInterval())
+
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	assert.Equal(t, time.Second, watchdogInterval())
+
+	// The watchdog is meant for another process
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	assert.Equal(t, time.Duration(0), watchdogInterval())
+}
+
+func TestActivationListener(t *testing.T) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+
+	listen, err := activationListener()
+	assert.Nil(t, err)
+	assert.Nil(t, listen)
+
+	// The sockets are meant for another process
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	os.Setenv("LISTEN_FDS", "1")
+
+	listen, err = activationListener()
+	assert.Nil(t, err)
+	assert.Nil(t, listen)
+
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "2")
+
+	_, err = activationListener()
+	assert.NotNil(t, err)
+
+	// The environment is cleared
+	assert.Equal(t, "", os.Getenv("LISTEN_PID"))
+	assert.Equal(t, "", os.Getenv("LISTEN_FDS"))
+}
+
+func TestNotifySystemd(t *testing.T) {
+	conn, cleanup := fakeNotifySocket(t)
+	defer cleanup()
+
+	os.Setenv("WATCHDOG_USEC", "200000")
+	defer os.Unsetenv("WATCHDOG_USEC")
+
+	k, err := startKSM(defaultKSMRoot, ksmInitial)
+	assert.Nil(t, err)
+	defer k.restore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		notifySystemd(ctx, k)
+		close(done)
+	}()
+
+	msg := readNotification(t, conn)
+	assert.True(t, strings.HasPrefix(msg, sdReady+"\n"))
+	assert.True(t, strings.Contains(msg, "STATUS=KSM mode initial"))
+
+	err = k.setMode(ksmSlow)
+	assert.Nil(t, err)
+
+	// Skip the watchdog pings until we get the new status
+	for {
+		msg = readNotification(t, conn)
+		if !strings.HasPrefix(msg, sdWatchdog) {
+			break
+		}
+	}
+	assert.Equal(t, "STATUS=KSM mode slow, slow policy", msg)
+
+	msg = readNotification(t, conn)
+	assert.True(t, strings.HasPrefix(msg, sdWatchdog+"\n"))
+
+	cancel()
+	<-done
+
+	for {
+		msg = readNotification(t, conn)
+		if !strings.HasPrefix(msg, sdWatchdog) {
+			break
+		}
+	}
+	assert.Equal(t, sdStopping, msg)
+}
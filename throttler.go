@@ -12,15 +12,20 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
+	"github.com/golang/protobuf/ptypes"
 	gpb "github.com/golang/protobuf/ptypes/empty"
 	kpb "github.com/kata-containers/ksm-throttler/pkg/grpc"
 	ksig "github.com/kata-containers/ksm-throttler/pkg/signals"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // name describes the program ans is set at build time
@@ -52,6 +57,7 @@ const (
 	ksmStop           = "0"
 	defaultKSMMode    = ksmAuto
 	defaultgRPCSocket = "/var/run/kata-ksm-throttler/ksm.sock"
+	defaultStateFile  = "/var/lib/kata-ksm-throttler/state.json"
 	// In linux the max socket path is 108 including null character
 	// see http://man7.org/linux/man-pages/man7/unix.7.html
 	socketPathMaxLength = 107
@@ -62,10 +68,20 @@ type ksmThrottleInterval struct {
 	nextKnob ksmMode
 }
 
+// ksmKickMode is the KSM mode we move to when getting kicked.
+// It is the head of the throttling down chain.
+var ksmKickMode = ksmAggressive
+
+// ksmAggressiveInterval is how long we stay in ksmKickMode.
 var ksmAggressiveInterval = 30 * time.Second
 var ksmStandardInterval = 120 * time.Second
 var ksmSlowInterval = 120 * time.Second
 
+// ksmKickWindow is how long after re-tuning KSM for a kick we coalesce
+// the next kicks: They only extend the hold, unless they ask for a more
+// aggressive mode.
+var ksmKickWindow = 2 * time.Second
+
 var ksmThrottleIntervals = map[ksmMode]ksmThrottleInterval{
 	ksmAggressive: {
 		// From aggressive: move to standard and wait 120s
@@ -126,22 +142,365 @@ func SetLoggingLevel(l string) error {
 type ksmThrottler struct {
 	k   *ksm
 	uri string
+
+	// metrics is the metrics service address, if any.
+	metrics string
+
+	// activated is set when systemd created our socket.
+	activated bool
+
+	// stopping is closed when the service stops, for the streaming
+	// calls to return.
+	stopping chan struct{}
+
+	// limiter rate limits the kicks of each client.
+	limiter *kickLimiter
+}
+
+// invalidArgument marks err as caused by an invalid request.
+func invalidArgument(err error) error {
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// grpcError maps our errors to gRPC status codes, for clients to tell
+// them apart.
+func grpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch err {
+	case errKSMUnavailable, errKSMMissing:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errKickRateLimited:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errKSMNotInitial:
+		return status.Error(codes.Aborted, err.Error())
+	}
+
+	return err
+}
+
+func unaryErrorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+
+	return resp, grpcError(err)
+}
+
+func streamErrorInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return grpcError(handler(srv, ss))
 }
 
 // Kick is the KSM Throttler gRPC Kick function implementation
-func (t *ksmThrottler) Kick(context.Context, *gpb.Empty) (*gpb.Empty, error) {
-	throttlerLog.Debug("Kick received")
+func (t *ksmThrottler) Kick(ctx context.Context, req *kpb.KickRequest) (*gpb.Empty, error) {
+	throttlerLog.WithFields(logrus.Fields{
+		"sandbox":  req.SandboxId,
+		"ksm-mode": req.Mode,
+		"reason":   req.Reason,
+	}).Debug("Kick received")
+
+	if t.k == nil {
+		return nil, errKSMMissing
+	}
+
+	var hold time.Duration
+	if req.Hold != nil {
+		var err error
+		if hold, err = ptypes.Duration(req.Hold); err != nil {
+			return nil, invalidArgument(err)
+		}
+	}
+
+	kick, err := newKSMKick(req.Mode, hold, req.Reason)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	var pids []int
+	for _, pid := range req.Pids {
+		pids = append(pids, int(pid))
+	}
+
+	if err := checkSandbox(req.SandboxId, pids); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	// Malformed kicks do not count against the rate limit
+	if !t.limiter.allow(kickClient(ctx), time.Now()) {
+		throttlerMetrics.kicksLimited.inc()
+		return nil, errKickRateLimited
+	}
+
+	if err := t.k.kickSandbox(req.SandboxId, pids, kick); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	return &gpb.Empty{}, nil
+}
+
+func sysfsValuesProto(v ksmValues) *kpb.SysfsValues {
+	return &kpb.SysfsValues{
+		Run:            v.run,
+		PagesToScan:    v.pagesToScan,
+		SleepMillisecs: v.sleepInterval,
+		Knobs:          v.knobs,
+	}
+}
+
+func statusProto(s ksmStatus) *kpb.StatusResponse {
+	return &kpb.StatusResponse{
+		Mode:              string(s.knob),
+		Throttling:        s.throttling,
+		ThrottleRemaining: ptypes.DurationProto(s.remaining),
+		Current:           sysfsValuesProto(s.current),
+		Initial:           sysfsValuesProto(s.initial),
+		Policy:            string(s.policy),
+		Sandboxes:         int32(s.sandboxes),
+	}
+}
+
+// Status is the KSM Throttler gRPC Status function implementation
+func (t *ksmThrottler) Status(context.Context, *gpb.Empty) (*kpb.StatusResponse, error) {
+	throttlerLog.Debug("Status received")
+
+	if t.k == nil {
+		return nil, errKSMMissing
+	}
+
+	s, err := t.k.status()
+	if err != nil {
+		return nil, err
+	}
+
+	return statusProto(s), nil
+}
+
+// SetMode is the KSM Throttler gRPC SetMode function implementation
+func (t *ksmThrottler) SetMode(ctx context.Context, req *kpb.SetModeRequest) (*kpb.StatusResponse, error) {
+	throttlerLog.WithField("ksm-mode", req.Mode).Debug("SetMode received")
+
+	if t.k == nil {
+		return nil, errKSMMissing
+	}
+
+	mode, err := parseKSMMode(req.Mode)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	if err := t.k.setMode(mode); err != nil {
+		return nil, err
+	}
+
+	return t.Status(ctx, &gpb.Empty{})
+}
+
+// Rebaseline is the KSM Throttler gRPC Rebaseline function implementation
+func (t *ksmThrottler) Rebaseline(ctx context.Context, req *gpb.Empty) (*kpb.StatusResponse, error) {
+	throttlerLog.Debug("Rebaseline received")
+
+	if t.k == nil {
+		return nil, errKSMMissing
+	}
+
+	if err := t.k.rebaseline(); err != nil {
+		return nil, err
+	}
+
+	return t.Status(ctx, &gpb.Empty{})
+}
+
+// GetStats is the KSM Throttler gRPC GetStats function implementation
+func (t *ksmThrottler) GetStats(context.Context, *gpb.Empty) (*kpb.Stats, error) {
+	throttlerLog.Debug("GetStats received")
+
+	if t.k == nil {
+		return nil, errKSMMissing
+	}
+
+	s, err := t.k.stats()
+	if err != nil {
+		return nil, err
+	}
+
+	return &kpb.Stats{
+		PagesShared:      s.pagesShared,
+		PagesSharing:     s.pagesSharing,
+		PagesUnshared:    s.pagesUnshared,
+		PagesVolatile:    s.pagesVolatile,
+		FullScans:        s.fullScans,
+		StableNodeChains: s.stableNodeChains,
+		GeneralProfit:    s.generalProfit,
+		SharingRatio:     s.sharingRatio(),
+		BytesSaved:       s.bytesSaved(),
+		Unavailable:      s.unavailable,
+	}, nil
+}
+
+func processStatsProto(s processStats) *kpb.ProcessStats {
+	return &kpb.ProcessStats{
+		Pid:           int32(s.pid),
+		MergingPages:  s.mergingPages,
+		RmapItems:     s.rmapItems,
+		ZeroPages:     s.zeroPages,
+		ProcessProfit: s.processProfit,
+	}
+}
+
+// GetProcessStats is the KSM Throttler gRPC GetProcessStats function implementation
+func (t *ksmThrottler) GetProcessStats(ctx context.Context, req *kpb.ProcessRequest) (*kpb.ProcessStats, error) {
+	throttlerLog.WithField("pid", req.Pid).Debug("GetProcessStats received")
+
+	if t.k == nil {
+		return nil, errKSMMissing
+	}
+
+	s, err := t.k.processStats(int(req.Pid))
+	if err != nil {
+		return nil, err
+	}
+
+	return processStatsProto(s), nil
+}
+
+// ListSandboxes is the KSM Throttler gRPC ListSandboxes function implementation
+func (t *ksmThrottler) ListSandboxes(context.Context, *gpb.Empty) (*kpb.ListSandboxesResponse, error) {
+	throttlerLog.Debug("ListSandboxes received")
 
 	if t.k == nil {
 		return nil, errKSMMissing
 	}
 
-	t.k.kick()
+	sandboxes, err := t.k.listSandboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &kpb.ListSandboxesResponse{}
+
+	for _, s := range sandboxes {
+		sandbox := &kpb.SandboxStats{
+			SandboxId:     s.id,
+			MergingPages:  s.mergingPages,
+			ProcessProfit: s.processProfit,
+		}
+
+		for _, p := range s.processes {
+			sandbox.Processes = append(sandbox.Processes, processStatsProto(p))
+		}
+
+		resp.Sandboxes = append(resp.Sandboxes, sandbox)
+	}
+
+	return resp, nil
+}
+
+// Release is the KSM Throttler gRPC Release function implementation
+func (t *ksmThrottler) Release(ctx context.Context, req *kpb.ReleaseRequest) (*gpb.Empty, error) {
+	throttlerLog.WithFields(logrus.Fields{
+		"sandbox": req.SandboxId,
+		"reason":  req.Reason,
+	}).Debug("Release received")
+
+	if t.k == nil {
+		return nil, errKSMMissing
+	}
+
+	var pids []int
+	for _, pid := range req.Pids {
+		pids = append(pids, int(pid))
+	}
+
+	if err := t.k.releaseSandbox(req.SandboxId, pids); err != nil {
+		return nil, invalidArgument(err)
+	}
 
 	return &gpb.Empty{}, nil
 }
 
-func (t *ksmThrottler) listen() (*net.UnixListener, error) {
+var eventTypes = map[ksmEventType]kpb.Event_Type{
+	ksmEventKick:       kpb.Event_KICK,
+	ksmEventTransition: kpb.Event_TRANSITION,
+	ksmEventRestore:    kpb.Event_RESTORE,
+	ksmEventError:      kpb.Event_ERROR,
+}
+
+func eventProto(e ksmEvent) (*kpb.Event, error) {
+	ts, err := ptypes.TimestampProto(e.time)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &kpb.Event{
+		Type:    eventTypes[e.kind],
+		Time:    ts,
+		OldMode: string(e.oldMode),
+		NewMode: string(e.newMode),
+	}
+
+	switch e.kind {
+	case ksmEventKick:
+		event.Kick = &kpb.KickRequest{
+			SandboxId: e.kick.sandbox,
+			Mode:      string(e.kick.mode),
+			Hold:      ptypes.DurationProto(e.kick.hold),
+			Reason:    e.kick.reason,
+		}
+
+	case ksmEventTransition, ksmEventRestore:
+		event.Values = sysfsValuesProto(e.values)
+
+	case ksmEventError:
+		event.Error = e.err.Error()
+	}
+
+	return event, nil
+}
+
+// Watch is the KSM Throttler gRPC Watch function implementation
+func (t *ksmThrottler) Watch(req *gpb.Empty, stream kpb.KSMThrottler_WatchServer) error {
+	throttlerLog.Debug("Watch received")
+
+	if t.k == nil {
+		return errKSMMissing
+	}
+
+	events, cancel := t.k.events.subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+
+		case <-t.stopping:
+			return nil
+
+		case e := <-events:
+			event, err := eventProto(e)
+			if err != nil {
+				return err
+			}
+
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (t *ksmThrottler) listen() (net.Listener, error) {
+	listen, err := activationListener()
+	if err != nil {
+		return nil, err
+	}
+
+	if listen != nil {
+		throttlerLog.WithField("address", listen.Addr()).Info("Using socket activation")
+		t.activated = true
+		return listen, nil
+	}
+
 	uriDir := filepath.Dir(t.uri)
 	if err := os.MkdirAll(uriDir, socketDirectoryPerm); err != nil {
 		return nil, fmt.Errorf("Couldn't create socket directory %v", err)
@@ -151,7 +510,7 @@ func (t *ksmThrottler) listen() (*net.UnixListener, error) {
 		return nil, fmt.Errorf("Couldn't remove exiting socket %v", err)
 	}
 
-	listen, err := net.ListenUnix("unix", &net.UnixAddr{Name: t.uri, Net: "unix"})
+	listen, err = net.ListenUnix("unix", &net.UnixAddr{Name: t.uri, Net: "unix"})
 	if err != nil {
 		return nil, fmt.Errorf("Listen error %v", err)
 
@@ -164,6 +523,126 @@ func (t *ksmThrottler) listen() (*net.UnixListener, error) {
 	return listen, nil
 }
 
+// run serves the KSM throttler gRPC service until ctx is done.
+// It then waits for the pending calls to complete, restores the
+// initial KSM settings and removes the service socket.
+func (t *ksmThrottler) run(ctx context.Context) error {
+	var err error
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	defer func() {
+		if t.k == nil {
+			return
+		}
+
+		if err := t.k.restore(); err != nil {
+			throttlerLog.WithError(err).Error("Could not restore KSM settings")
+		}
+	}()
+
+	if t.metrics != "" {
+		throttlerLog.WithField("address", t.metrics).Debug("Starting metrics service")
+
+		if err := serveMetrics(ctx, t.metrics, t.k); err != nil {
+			return err
+		}
+	}
+
+	throttlerLog.WithField("uri", t.uri).Debug("Starting KSM throttling service")
+
+	listen, err := t.listen()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		// systemd owns socket activated sockets
+		if t.activated {
+			return
+		}
+
+		if err := os.Remove(t.uri); err != nil && !os.IsNotExist(err) {
+			throttlerLog.WithError(err).Error("Could not remove socket")
+		}
+	}()
+
+	t.stopping = make(chan struct{})
+
+	if t.limiter == nil {
+		t.limiter = newKickLimiter(kickRate, kickBurst)
+	}
+
+	server := grpc.NewServer(
+		grpc.Creds(peerCredentials{}),
+		grpc.UnaryInterceptor(unaryErrorInterceptor),
+		grpc.StreamInterceptor(streamErrorInterceptor))
+	kpb.RegisterKSMThrottlerServer(server, t)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listen)
+	}()
+
+	go notifySystemd(ctx, t.k)
+
+	select {
+	case <-ctx.Done():
+		throttlerLog.Debug("Stopping KSM throttling service")
+
+		// Wait for the pending calls to complete
+		close(t.stopping)
+		server.GracefulStop()
+		<-serveErr
+		return nil
+
+	case err = <-serveErr:
+		return fmt.Errorf("gRPC serve error %v", err)
+	}
+}
+
+// setupSignalHandler calls cancel when we're asked to terminate.
+func setupSignalHandler(cancel context.CancelFunc) {
+	sigCh := make(chan os.Signal, 8)
+
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	for _, sig := range ksig.HandledSignals() {
+		signal.Notify(sigCh, sig)
+	}
+
+	go func() {
+		for {
+			// Block waiting for a signal
+			sig := <-sigCh
+
+			if sig == os.Interrupt || sig == syscall.SIGTERM {
+				throttlerLog.WithField("signal", sig).Info("terminating")
+				cancel()
+				continue
+			}
+
+			nativeSignal, ok := sig.(syscall.Signal)
+			if !ok {
+				err := errors.New("unknown signal")
+				throttlerLog.WithError(err).WithField("signal", sig.String()).Error()
+				continue
+			}
+
+			if ksig.FatalSignal(nativeSignal) {
+				throttlerLog.WithField("signal", sig).Error("received fatal signal")
+				ksig.Die()
+			} else if ksig.NonFatalSignal(nativeSignal) {
+				if debug {
+					throttlerLog.WithField("signal", sig).Debug("handling signal")
+					ksig.Backtrace()
+				}
+			}
+		}
+	}()
+}
+
 // getSocketPath computes the path of the KSM throttler socket.
 // Note that when socket activated, the socket path is specified
 // in the systemd socket file but the same value is set in
@@ -195,6 +674,16 @@ func realMain() {
 	doVersion := flag.Bool("version", false, "display the version")
 	logLevel := flag.String("log", "warn",
 		"log messages above specified level; one of debug, warn, error, fatal or panic")
+	configPath := flag.String("config", "", "path to the TOML configuration file")
+	modeArg := flag.String("mode", defaultKSMMode.String(),
+		"KSM mode; one of initial, off, slow, standard, aggressive, auto, advisor, adaptive, controller or budget")
+	ksmRoot := flag.String("ksm-root", defaultKSMRoot, "KSM sysfs root directory")
+	scanBasisArg := flag.String("scan-basis", "",
+		"pages the KSM modes pages_to_scan is computed from; one of anon or mergeable (default anon)")
+	metricsAddr := flag.String("metrics", "",
+		"serve Prometheus metrics on the specified address (e.g. :9420); disabled if empty")
+	stateFile := flag.String("state-file", defaultStateFile,
+		"file persisting the initial KSM settings across restarts; disabled if empty")
 
 	flag.Parse()
 
@@ -210,36 +699,62 @@ func realMain() {
 
 	ksig.SetLogger(throttlerLog)
 
-	uri, err := getSocketPath()
-	if err != nil {
-		throttlerLog.WithError(err).Error("Could net get service socket URI")
-		os.Exit(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	setupSignalHandler(cancel)
+
+	if *configPath != "" {
+		config, err := loadConfig(*configPath)
+		if err != nil {
+			throttlerLog.WithError(err).Error("Could not load configuration")
+			os.Exit(1)
+		}
+
+		if err := config.apply(); err != nil {
+			throttlerLog.WithError(err).WithField("config", *configPath).Error("Invalid configuration")
+			os.Exit(1)
+		}
 	}
 
-	ksm, err := startKSM(defaultKSMRoot, defaultKSMMode)
+	mode, err := parseKSMMode(*modeArg)
 	if err != nil {
-		throttlerLog.WithError(err).Error("Could not start KSM")
+		throttlerLog.WithError(err).Error("Could not parse KSM mode")
 		os.Exit(1)
 	}
 
-	throttler := &ksmThrottler{
-		k:   ksm,
-		uri: uri,
+	if *scanBasisArg != "" {
+		basis, err := parseScanBasis(*scanBasisArg)
+		if err != nil {
+			throttlerLog.WithError(err).Error("Could not parse scan basis")
+			os.Exit(1)
+		}
+
+		ksmScanBasis = basis
+	}
+
+	uri, err := getSocketPath()
+	if err != nil {
+		throttlerLog.WithError(err).Error("Could net get service socket URI")
+		os.Exit(1)
 	}
 
-	throttlerLog.WithField("uri", throttler.uri).Debug("Starting KSM throttling service")
+	ksmStateFile = *stateFile
 
-	listen, err := throttler.listen()
+	ksm, err := startKSM(*ksmRoot, mode)
 	if err != nil {
-		throttlerLog.WithError(err).Error("Could not listen on gRPC service")
+		throttlerLog.WithError(err).Error("Could not start KSM")
 		os.Exit(1)
 	}
 
-	server := grpc.NewServer()
-	kpb.RegisterKSMThrottlerServer(server, throttler)
+	throttler := &ksmThrottler{
+		k:       ksm,
+		uri:     uri,
+		metrics: *metricsAddr,
+	}
 
-	if err := server.Serve(listen); err != nil {
-		throttlerLog.WithError(err).Error("gRPC serve error")
+	if err := throttler.run(ctx); err != nil {
+		throttlerLog.WithError(err).Error("KSM throttling service error")
 		os.Exit(1)
 	}
 }
@@ -9,8 +9,20 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	gpb "github.com/golang/protobuf/ptypes/empty"
+	kpb "github.com/kata-containers/ksm-throttler/pkg/grpc"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestMain(m *testing.M) {
@@ -34,3 +46,159 @@ func TestMain(m *testing.M) {
 
 	os.Exit(exit)
 }
+
+func TestThrottlerStatus(t *testing.T) {
+	throttler := &ksmThrottler{}
+
+	_, err := throttler.Status(context.Background(), &gpb.Empty{})
+	assert.Equal(t, errKSMMissing, err)
+
+	k, err := startKSM(defaultKSMRoot, ksmOff)
+	assert.Nil(t, err)
+	defer k.restore()
+
+	throttler.k = k
+
+	s, err := throttler.Status(context.Background(), &gpb.Empty{})
+	assert.Nil(t, err)
+	assert.Equal(t, string(ksmOff), s.Mode)
+	assert.False(t, s.Throttling)
+	assert.Equal(t, ksmStop, s.Current.Run)
+	assert.Equal(t, k.initialKSMRun, s.Initial.Run)
+}
+
+func TestThrottlerSetMode(t *testing.T) {
+	k, err := startKSM(defaultKSMRoot, ksmInitial)
+	assert.Nil(t, err)
+	defer k.restore()
+
+	throttler := &ksmThrottler{k: k}
+
+	s, err := throttler.SetMode(context.Background(), &kpb.SetModeRequest{Mode: "standard"})
+	assert.Nil(t, err)
+	assert.Equal(t, string(ksmStandard), s.Mode)
+	assert.Equal(t, string(ksmStandard), s.Policy)
+	assert.False(t, s.Throttling)
+
+	s, err = throttler.SetMode(context.Background(), &kpb.SetModeRequest{Mode: "auto"})
+	assert.Nil(t, err)
+	assert.Equal(t, string(ksmInitial), s.Mode)
+	assert.Equal(t, string(ksmAuto), s.Policy)
+	assert.True(t, s.Throttling)
+
+	_, err = throttler.SetMode(context.Background(), &kpb.SetModeRequest{Mode: "foo"})
+	st, ok := status.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+}
+
+func TestGRPCError(t *testing.T) {
+	for err, code := range map[error]codes.Code{
+		errKSMUnavailable:                    codes.FailedPrecondition,
+		errKSMMissing:                        codes.FailedPrecondition,
+		errKickRateLimited:                   codes.ResourceExhausted,
+		errKSMNotInitial:                     codes.Aborted,
+		invalidArgument(errMissingSandboxID): codes.InvalidArgument,
+		fmt.Errorf("failure"):                codes.Unknown,
+	} {
+		st, _ := status.FromError(grpcError(err))
+		if st == nil {
+			assert.Equal(t, codes.Unknown, code)
+			continue
+		}
+
+		assert.Equal(t, code, st.Code(), "%v", err)
+	}
+
+	assert.Nil(t, grpcError(nil))
+}
+
+func TestThrottlerRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ksmthrottler-run")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	k, err := startKSM(defaultKSMRoot, ksmAuto)
+	assert.Nil(t, err)
+
+	throttler := &ksmThrottler{
+		k:       k,
+		uri:     filepath.Join(dir, "ksm.sock"),
+		limiter: newKickLimiter(0.001, 1),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+
+	go func() {
+		runErr <- throttler.run(ctx)
+	}()
+
+	conn, err := grpc.Dial(throttler.uri, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second),
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", addr, timeout)
+		}))
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	client := kpb.NewKSMThrottlerClient(conn)
+
+	watchCtx, watchCancel := context.WithCancel(context.Background())
+	defer watchCancel()
+
+	watch, err := client.Watch(watchCtx, &gpb.Empty{})
+	assert.Nil(t, err)
+
+	// Wait for the watcher to be registered
+	for i := 0; i < 100 && k.events.count() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Malformed kicks do not use the client kicks up
+	_, err = client.Kick(context.Background(), &kpb.KickRequest{Mode: "fastest"})
+	st, ok := status.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+
+	_, err = client.Kick(context.Background(), &kpb.KickRequest{Reason: "test"})
+	assert.Nil(t, err)
+
+	event, err := watch.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, kpb.Event_KICK, event.Type)
+	assert.Equal(t, "test", event.Kick.Reason)
+	assert.Equal(t, string(ksmAggressive), event.Kick.Mode)
+
+	event, err = watch.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, kpb.Event_TRANSITION, event.Type)
+	assert.Equal(t, string(ksmAggressive), event.NewMode)
+	assert.Equal(t, ksmStart, event.Values.Run)
+
+	// We only get one kick through, whatever the sandbox ID
+	limited := throttlerMetrics.kicksLimited.get()
+	for _, id := range []string{"", "sandbox-1", "sandbox-2"} {
+		_, err = client.Kick(context.Background(), &kpb.KickRequest{SandboxId: id, Reason: "test"})
+		st, ok = status.FromError(err)
+		assert.True(t, ok)
+		assert.Equal(t, codes.ResourceExhausted, st.Code())
+	}
+	assert.Equal(t, limited+3, throttlerMetrics.kicksLimited.get())
+
+	cancel()
+
+	select {
+	case err = <-runErr:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("KSM throttler did not stop")
+	}
+
+	// The throttling goroutine is gone, the KSM settings are restored
+	// and the socket is removed.
+	assert.False(t, k.initialized)
+	assert.Nil(t, k.throttleStop)
+
+	_, err = os.Stat(throttler.uri)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,389 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: containerd.proto
+
+/*
+Package api is a generated protocol buffer package.
+
+It is generated from these files:
+	containerd.proto
+
+It has these top-level messages:
+	SubscribeRequest
+	Envelope
+	TaskCreate
+	TaskDelete
+	SandboxEvent
+	Runtime
+	Container
+	GetContainerRequest
+	GetContainerResponse
+	Sandbox
+	StoreGetRequest
+	StoreGetResponse
+*/
+package api
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import google_protobuf "github.com/golang/protobuf/ptypes/any"
+import google_protobuf1 "github.com/golang/protobuf/ptypes/timestamp"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// containerd.services.events.v1.SubscribeRequest
+type SubscribeRequest struct {
+	Filters []string `protobuf:"bytes,1,rep,name=filters" json:"filters,omitempty"`
+}
+
+func (m *SubscribeRequest) Reset()                    { *m = SubscribeRequest{} }
+func (m *SubscribeRequest) String() string            { return proto.CompactTextString(m) }
+func (*SubscribeRequest) ProtoMessage()               {}
+func (*SubscribeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *SubscribeRequest) GetFilters() []string {
+	if m != nil {
+		return m.Filters
+	}
+	return nil
+}
+
+// containerd.services.events.v1.Envelope
+type Envelope struct {
+	Timestamp *google_protobuf1.Timestamp `protobuf:"bytes,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	Namespace string                      `protobuf:"bytes,2,opt,name=namespace" json:"namespace,omitempty"`
+	Topic     string                      `protobuf:"bytes,3,opt,name=topic" json:"topic,omitempty"`
+	Event     *google_protobuf.Any        `protobuf:"bytes,4,opt,name=event" json:"event,omitempty"`
+}
+
+func (m *Envelope) Reset()                    { *m = Envelope{} }
+func (m *Envelope) String() string            { return proto.CompactTextString(m) }
+func (*Envelope) ProtoMessage()               {}
+func (*Envelope) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *Envelope) GetTimestamp() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
+func (m *Envelope) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *Envelope) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *Envelope) GetEvent() *google_protobuf.Any {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+// containerd.events.TaskCreate
+type TaskCreate struct {
+	ContainerId string `protobuf:"bytes,1,opt,name=container_id,json=containerId" json:"container_id,omitempty"`
+	Pid         uint32 `protobuf:"varint,6,opt,name=pid" json:"pid,omitempty"`
+}
+
+func (m *TaskCreate) Reset()                    { *m = TaskCreate{} }
+func (m *TaskCreate) String() string            { return proto.CompactTextString(m) }
+func (*TaskCreate) ProtoMessage()               {}
+func (*TaskCreate) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *TaskCreate) GetContainerId() string {
+	if m != nil {
+		return m.ContainerId
+	}
+	return ""
+}
+
+func (m *TaskCreate) GetPid() uint32 {
+	if m != nil {
+		return m.Pid
+	}
+	return 0
+}
+
+// containerd.events.TaskDelete
+type TaskDelete struct {
+	ContainerId string `protobuf:"bytes,1,opt,name=container_id,json=containerId" json:"container_id,omitempty"`
+	Pid         uint32 `protobuf:"varint,2,opt,name=pid" json:"pid,omitempty"`
+	// id is set when an exec process, not the container task, exits.
+	Id string `protobuf:"bytes,5,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *TaskDelete) Reset()                    { *m = TaskDelete{} }
+func (m *TaskDelete) String() string            { return proto.CompactTextString(m) }
+func (*TaskDelete) ProtoMessage()               {}
+func (*TaskDelete) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *TaskDelete) GetContainerId() string {
+	if m != nil {
+		return m.ContainerId
+	}
+	return ""
+}
+
+func (m *TaskDelete) GetPid() uint32 {
+	if m != nil {
+		return m.Pid
+	}
+	return 0
+}
+
+func (m *TaskDelete) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+// containerd.events.SandboxCreate, SandboxStart and SandboxExit
+type SandboxEvent struct {
+	SandboxId string `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId" json:"sandbox_id,omitempty"`
+}
+
+func (m *SandboxEvent) Reset()                    { *m = SandboxEvent{} }
+func (m *SandboxEvent) String() string            { return proto.CompactTextString(m) }
+func (*SandboxEvent) ProtoMessage()               {}
+func (*SandboxEvent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *SandboxEvent) GetSandboxId() string {
+	if m != nil {
+		return m.SandboxId
+	}
+	return ""
+}
+
+// containerd.services.containers.v1.Container.Runtime and
+// containerd.types.Sandbox.Runtime
+type Runtime struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *Runtime) Reset()                    { *m = Runtime{} }
+func (m *Runtime) String() string            { return proto.CompactTextString(m) }
+func (*Runtime) ProtoMessage()               {}
+func (*Runtime) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *Runtime) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+// containerd.services.containers.v1.Container
+type Container struct {
+	Id      string            `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Labels  map[string]string `protobuf:"bytes,2,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Image   string            `protobuf:"bytes,3,opt,name=image" json:"image,omitempty"`
+	Runtime *Runtime          `protobuf:"bytes,4,opt,name=runtime" json:"runtime,omitempty"`
+}
+
+func (m *Container) Reset()                    { *m = Container{} }
+func (m *Container) String() string            { return proto.CompactTextString(m) }
+func (*Container) ProtoMessage()               {}
+func (*Container) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *Container) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Container) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *Container) GetImage() string {
+	if m != nil {
+		return m.Image
+	}
+	return ""
+}
+
+func (m *Container) GetRuntime() *Runtime {
+	if m != nil {
+		return m.Runtime
+	}
+	return nil
+}
+
+// containerd.services.containers.v1.GetContainerRequest
+type GetContainerRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *GetContainerRequest) Reset()                    { *m = GetContainerRequest{} }
+func (m *GetContainerRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetContainerRequest) ProtoMessage()               {}
+func (*GetContainerRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *GetContainerRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+// containerd.services.containers.v1.GetContainerResponse
+type GetContainerResponse struct {
+	Container *Container `protobuf:"bytes,1,opt,name=container" json:"container,omitempty"`
+}
+
+func (m *GetContainerResponse) Reset()                    { *m = GetContainerResponse{} }
+func (m *GetContainerResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetContainerResponse) ProtoMessage()               {}
+func (*GetContainerResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *GetContainerResponse) GetContainer() *Container {
+	if m != nil {
+		return m.Container
+	}
+	return nil
+}
+
+// containerd.types.Sandbox
+type Sandbox struct {
+	SandboxId string            `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId" json:"sandbox_id,omitempty"`
+	Runtime   *Runtime          `protobuf:"bytes,2,opt,name=runtime" json:"runtime,omitempty"`
+	Labels    map[string]string `protobuf:"bytes,4,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *Sandbox) Reset()                    { *m = Sandbox{} }
+func (m *Sandbox) String() string            { return proto.CompactTextString(m) }
+func (*Sandbox) ProtoMessage()               {}
+func (*Sandbox) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *Sandbox) GetSandboxId() string {
+	if m != nil {
+		return m.SandboxId
+	}
+	return ""
+}
+
+func (m *Sandbox) GetRuntime() *Runtime {
+	if m != nil {
+		return m.Runtime
+	}
+	return nil
+}
+
+func (m *Sandbox) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+// containerd.services.sandbox.v1.StoreGetRequest
+type StoreGetRequest struct {
+	SandboxId string `protobuf:"bytes,1,opt,name=sandbox_id,json=sandboxId" json:"sandbox_id,omitempty"`
+}
+
+func (m *StoreGetRequest) Reset()                    { *m = StoreGetRequest{} }
+func (m *StoreGetRequest) String() string            { return proto.CompactTextString(m) }
+func (*StoreGetRequest) ProtoMessage()               {}
+func (*StoreGetRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *StoreGetRequest) GetSandboxId() string {
+	if m != nil {
+		return m.SandboxId
+	}
+	return ""
+}
+
+// containerd.services.sandbox.v1.StoreGetResponse
+type StoreGetResponse struct {
+	Sandbox *Sandbox `protobuf:"bytes,1,opt,name=sandbox" json:"sandbox,omitempty"`
+}
+
+func (m *StoreGetResponse) Reset()                    { *m = StoreGetResponse{} }
+func (m *StoreGetResponse) String() string            { return proto.CompactTextString(m) }
+func (*StoreGetResponse) ProtoMessage()               {}
+func (*StoreGetResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *StoreGetResponse) GetSandbox() *Sandbox {
+	if m != nil {
+		return m.Sandbox
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*SubscribeRequest)(nil), "containerd.SubscribeRequest")
+	proto.RegisterType((*Envelope)(nil), "containerd.Envelope")
+	proto.RegisterType((*TaskCreate)(nil), "containerd.TaskCreate")
+	proto.RegisterType((*TaskDelete)(nil), "containerd.TaskDelete")
+	proto.RegisterType((*SandboxEvent)(nil), "containerd.SandboxEvent")
+	proto.RegisterType((*Runtime)(nil), "containerd.Runtime")
+	proto.RegisterType((*Container)(nil), "containerd.Container")
+	proto.RegisterType((*GetContainerRequest)(nil), "containerd.GetContainerRequest")
+	proto.RegisterType((*GetContainerResponse)(nil), "containerd.GetContainerResponse")
+	proto.RegisterType((*Sandbox)(nil), "containerd.Sandbox")
+	proto.RegisterType((*StoreGetRequest)(nil), "containerd.StoreGetRequest")
+	proto.RegisterType((*StoreGetResponse)(nil), "containerd.StoreGetResponse")
+}
+
+func init() { proto.RegisterFile("containerd.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 528 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x53, 0x5d, 0x6f, 0xd3, 0x30,
+	0x14, 0x95, 0xd3, 0x2f, 0x72, 0x3b, 0xa0, 0xf2, 0x8a, 0x14, 0x2a, 0xa6, 0x75, 0x91, 0x90, 0x2a,
+	0x44, 0x33, 0xb4, 0x3d, 0xb0, 0xf1, 0x56, 0x46, 0x35, 0x4d, 0xf0, 0x82, 0xbb, 0x27, 0x5e, 0x90,
+	0xd3, 0xdc, 0x55, 0xd6, 0x52, 0x27, 0x24, 0x6e, 0x45, 0xff, 0x0f, 0xff, 0x86, 0x77, 0x7e, 0x0f,
+	0x8a, 0x63, 0x27, 0x65, 0x9b, 0x54, 0xa1, 0xbd, 0xe5, 0x5e, 0x1f, 0xdf, 0x73, 0x7c, 0xce, 0x0d,
+	0xf4, 0xe6, 0x89, 0x54, 0x5c, 0x48, 0xcc, 0xa2, 0x20, 0xcd, 0x12, 0x95, 0x50, 0xa8, 0x3b, 0x83,
+	0x97, 0x8b, 0x24, 0x59, 0xc4, 0x78, 0xac, 0x4f, 0xc2, 0xd5, 0xcd, 0x31, 0x97, 0x9b, 0x12, 0x36,
+	0x38, 0xbc, 0x7b, 0xa4, 0xc4, 0x12, 0x73, 0xc5, 0x97, 0x69, 0x09, 0xf0, 0xdf, 0x42, 0x6f, 0xb6,
+	0x0a, 0xf3, 0x79, 0x26, 0x42, 0x64, 0xf8, 0x63, 0x85, 0xb9, 0xa2, 0x1e, 0x74, 0x6e, 0x44, 0xac,
+	0x30, 0xcb, 0x3d, 0x32, 0x6c, 0x8c, 0x5c, 0x66, 0x4b, 0xff, 0x17, 0x81, 0x27, 0x53, 0xb9, 0xc6,
+	0x38, 0x49, 0x91, 0x9e, 0x81, 0x5b, 0x4d, 0xf3, 0xc8, 0x90, 0x8c, 0xba, 0x27, 0x83, 0xa0, 0xe4,
+	0x0b, 0x2c, 0x5f, 0x70, 0x6d, 0x11, 0xac, 0x06, 0xd3, 0x57, 0xe0, 0x4a, 0xbe, 0xc4, 0x3c, 0xe5,
+	0x73, 0xf4, 0x9c, 0x21, 0x19, 0xb9, 0xac, 0x6e, 0xd0, 0x3e, 0xb4, 0x54, 0x92, 0x8a, 0xb9, 0xd7,
+	0xd0, 0x27, 0x65, 0x41, 0xdf, 0x40, 0x0b, 0xd7, 0x28, 0x95, 0xd7, 0xd4, 0x4c, 0xfd, 0x7b, 0x4c,
+	0x13, 0xb9, 0x61, 0x25, 0xc4, 0x9f, 0x00, 0x5c, 0xf3, 0xfc, 0xf6, 0x22, 0x43, 0xae, 0x90, 0x1e,
+	0xc1, 0x5e, 0x65, 0xd6, 0x77, 0x11, 0x69, 0xa9, 0x2e, 0xeb, 0x56, 0xbd, 0xab, 0x88, 0xf6, 0xa0,
+	0x91, 0x8a, 0xc8, 0x6b, 0x0f, 0xc9, 0xe8, 0x29, 0x2b, 0x3e, 0xfd, 0xaf, 0xe5, 0x88, 0x4f, 0x18,
+	0xe3, 0x7f, 0x8d, 0x70, 0xaa, 0x11, 0xf4, 0x19, 0x38, 0x22, 0xf2, 0x5a, 0x1a, 0xea, 0x88, 0xc8,
+	0x1f, 0xc3, 0xde, 0x8c, 0xcb, 0x28, 0x4c, 0x7e, 0x4e, 0x0b, 0x95, 0xf4, 0x00, 0x20, 0x2f, 0xeb,
+	0x7a, 0xa4, 0x6b, 0x3a, 0x57, 0x91, 0x7f, 0x00, 0x1d, 0xb6, 0x92, 0x85, 0x69, 0x94, 0x42, 0xb3,
+	0xb0, 0xc7, 0x60, 0xf4, 0xb7, 0xff, 0x87, 0x80, 0x7b, 0x61, 0xf9, 0x0d, 0x17, 0xb1, 0x5c, 0xf4,
+	0x1c, 0xda, 0x31, 0x0f, 0x31, 0xce, 0x3d, 0x67, 0xd8, 0x18, 0x75, 0x4f, 0x8e, 0x82, 0xad, 0x0d,
+	0xaa, 0xae, 0x05, 0x5f, 0x34, 0x66, 0x2a, 0x55, 0xb6, 0x61, 0xe6, 0x42, 0x61, 0xbf, 0x58, 0xf2,
+	0x05, 0x5a, 0xfb, 0x75, 0x41, 0xc7, 0xd0, 0xc9, 0x4a, 0x35, 0x26, 0x80, 0xfd, 0xed, 0x89, 0x46,
+	0x28, 0xb3, 0x98, 0xc1, 0x39, 0x74, 0xb7, 0x66, 0x17, 0xe6, 0xdc, 0xe2, 0xc6, 0xe8, 0x2b, 0x3e,
+	0x0b, 0x96, 0x35, 0x8f, 0x57, 0x36, 0xfe, 0xb2, 0xf8, 0xe0, 0x9c, 0x11, 0xff, 0x35, 0xec, 0x5f,
+	0xa2, 0xaa, 0x34, 0xda, 0xa5, 0xbc, 0xf3, 0x42, 0xff, 0x33, 0xf4, 0xff, 0x85, 0xe5, 0x69, 0x22,
+	0x73, 0xa4, 0xa7, 0xe0, 0x56, 0xc2, 0xcc, 0x56, 0xbe, 0x78, 0xf0, 0xf1, 0xac, 0xc6, 0xf9, 0xbf,
+	0x09, 0x74, 0x4c, 0x36, 0x3b, 0x62, 0xd9, 0x36, 0xc2, 0xd9, 0x6d, 0x04, 0x7d, 0x5f, 0x05, 0xd1,
+	0xd4, 0x41, 0x1c, 0x6e, 0xa3, 0x0d, 0xe5, 0x43, 0x31, 0x3c, 0xc6, 0xc1, 0x77, 0xf0, 0x7c, 0xa6,
+	0x92, 0x0c, 0x2f, 0x51, 0x59, 0xf7, 0x76, 0xec, 0xda, 0x04, 0x7a, 0xf5, 0x0d, 0x63, 0xe4, 0x18,
+	0x3a, 0x06, 0xe0, 0x91, 0xfb, 0x0f, 0x35, 0xd2, 0x99, 0xc5, 0x7c, 0x6c, 0x7d, 0x6b, 0xf0, 0x54,
+	0x84, 0x6d, 0xfd, 0x3f, 0x9e, 0xfe, 0x1d, 0x00, 0x97, 0x20, 0xa8, 0x6f, 0xb2, 0x04, 0x00, 0x00,
+}
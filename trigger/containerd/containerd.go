@@ -0,0 +1,134 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kata-containers/ksm-throttler/pkg/client"
+	ksig "github.com/kata-containers/ksm-throttler/pkg/signals"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// DefaultURI is populated at link time - see the Makefile
+var DefaultURI string
+
+var triggerLog = logrus.WithFields(logrus.Fields{
+	"source": "throttler-trigger",
+	"name":   "containerd",
+	"pid":    os.Getpid(),
+})
+
+const (
+	defaultgRPCSocket       = "/var/run/kata-ksm-throttler/ksm.sock"
+	defaultContainerdSocket = "/run/containerd/containerd.sock"
+
+	// defaultRuntime matches all the Kata runtime shims, e.g.
+	// io.containerd.kata.v2 or io.containerd.kata-qemu.v2.
+	defaultRuntime = "io.containerd.kata"
+)
+
+func setLoggingLevel(l string) error {
+	level, err := logrus.ParseLevel(l)
+	if err != nil {
+		return err
+	}
+
+	triggerLog.Logger.SetLevel(level)
+
+	triggerLog.Logger.Formatter = &logrus.TextFormatter{TimestampFormat: time.RFC3339Nano}
+
+	return nil
+}
+
+func setupSignalHandler(cancel context.CancelFunc) {
+	sigCh := make(chan os.Signal, 8)
+
+	for _, sig := range ksig.HandledSignals() {
+		signal.Notify(sigCh, sig)
+	}
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		for {
+			sig := <-sigCh
+
+			nativeSignal, ok := sig.(syscall.Signal)
+			if !ok {
+				err := errors.New("unknown signal")
+				triggerLog.WithError(err).WithField("signal", sig.String()).Error()
+				continue
+			}
+
+			if nativeSignal == syscall.SIGINT || nativeSignal == syscall.SIGTERM {
+				triggerLog.WithField("signal", sig).Debug("stopping")
+				cancel()
+			} else if ksig.FatalSignal(nativeSignal) {
+				triggerLog.WithField("signal", sig).Error("received fatal signal")
+				ksig.Die()
+			} else if ksig.NonFatalSignal(nativeSignal) {
+				triggerLog.WithField("signal", sig).Debug("handling signal")
+				ksig.Backtrace()
+			}
+		}
+	}()
+}
+
+func main() {
+	uri := flag.String("uri", "", "KSM throttler gRPC URI")
+	address := flag.String("address", defaultContainerdSocket, "containerd gRPC socket")
+	runtime := flag.String("runtime", defaultRuntime, "runtime name prefix of the sandboxes to kick the throttler for")
+	logLevel := flag.String("log", "warn",
+		"log messages above specified level; one of debug, warn, error, fatal or panic")
+	flag.Parse()
+
+	if err := setLoggingLevel(*logLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not set logging level %s: %v", *logLevel, err)
+		os.Exit(1)
+	}
+
+	ksig.SetLogger(triggerLog)
+
+	// Invoking "go build" without any linker option will not
+	// populate DefaultURI, so fallback to a reasonable path.
+	if DefaultURI == "" {
+		DefaultURI = defaultgRPCSocket
+	}
+
+	if *uri == "" {
+		*uri = DefaultURI
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	setupSignalHandler(cancel)
+
+	throttler, err := client.New(*uri, client.DefaultOptions())
+	if err != nil {
+		triggerLog.WithError(err).WithField("throttler", *uri).Error("Could not create KSM throttler client")
+		os.Exit(1)
+	}
+	defer throttler.Close()
+
+	watcher, err := newEventWatcher(*address, *runtime, throttler)
+	if err != nil {
+		triggerLog.WithError(err).WithField("containerd", *address).Error("Could not connect to containerd")
+		os.Exit(1)
+	}
+	defer watcher.close()
+
+	if err := watcher.run(ctx); err != nil {
+		triggerLog.WithError(err).Error("Could not monitor containerd events")
+		os.Exit(1)
+	}
+}
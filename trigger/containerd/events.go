@@ -0,0 +1,282 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	kpb "github.com/kata-containers/ksm-throttler/pkg/grpc"
+	"github.com/kata-containers/ksm-throttler/trigger/containerd/api"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// The containerd gRPC methods we call.
+const (
+	eventsSubscribeMethod = "/containerd.services.events.v1.Events/Subscribe"
+	containersGetMethod   = "/containerd.services.containers.v1.Containers/Get"
+	sandboxStoreGetMethod = "/containerd.services.sandbox.v1.Store/Get"
+)
+
+// The containerd event topics we subscribe to.
+const (
+	topicTaskCreate    = "/tasks/create"
+	topicTaskDelete    = "/tasks/delete"
+	topicSandboxCreate = "/sandboxes/create"
+	topicSandboxExit   = "/sandboxes/exit"
+)
+
+var eventFilters = []string{
+	`topic=="` + topicTaskCreate + `"`,
+	`topic=="` + topicTaskDelete + `"`,
+	`topic=="` + topicSandboxCreate + `"`,
+	`topic=="` + topicSandboxExit + `"`,
+}
+
+const (
+	// namespaceHeader is the gRPC metadata key containerd reads the
+	// namespace of a request from.
+	namespaceHeader = "containerd-namespace"
+
+	// The CRI plugin labels its containers with their kind: The
+	// sandbox (pause) container or a container running in a sandbox.
+	criKindLabel     = "io.cri-containerd.kind"
+	criKindContainer = "container"
+
+	// subscribeRetryDelay is how long we wait before subscribing again,
+	// once our subscription broke.
+	subscribeRetryDelay = time.Second
+
+	// kickTimeout bounds each kick.
+	kickTimeout = 10 * time.Second
+)
+
+var subscribeStream = &grpc.StreamDesc{
+	StreamName:    "Subscribe",
+	ServerStreams: true,
+}
+
+// kicker kicks the KSM throttler.
+type kicker interface {
+	Kick(ctx context.Context, req *kpb.KickRequest) error
+}
+
+// eventWatcher kicks the KSM throttler for every new sandbox running
+// with a Kata runtime, as reported by the containerd events.
+type eventWatcher struct {
+	conn      *grpc.ClientConn
+	runtime   string
+	throttler kicker
+
+	// sandboxes are the sandboxes we kicked for, until they go away.
+	// A sandbox can show up both as a CRI sandbox and as a task.
+	sandboxes map[string]struct{}
+
+	retryDelay time.Duration
+}
+
+// newEventWatcher connects to the containerd socket at address. Only
+// the sandboxes whose runtime name starts with runtime kick the
+// throttler.
+func newEventWatcher(address, runtime string, throttler kicker) (*eventWatcher, error) {
+	conn, err := grpc.Dial(address, grpc.WithInsecure(),
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", addr, timeout)
+		}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventWatcher{
+		conn:       conn,
+		runtime:    runtime,
+		throttler:  throttler,
+		sandboxes:  make(map[string]struct{}),
+		retryDelay: subscribeRetryDelay,
+	}, nil
+}
+
+func (w *eventWatcher) close() error {
+	return w.conn.Close()
+}
+
+// run watches the containerd events until ctx is cancelled, subscribing
+// again whenever containerd goes away.
+func (w *eventWatcher) run(ctx context.Context) error {
+	for {
+		err := w.watch(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		triggerLog.WithError(err).Warn("containerd events subscription failed")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(w.retryDelay):
+		}
+	}
+}
+
+func (w *eventWatcher) subscribe(ctx context.Context) (grpc.ClientStream, error) {
+	// Wait for containerd to be up, instead of failing right away
+	stream, err := grpc.NewClientStream(ctx, subscribeStream, w.conn, eventsSubscribeMethod, grpc.FailFast(false))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := stream.SendMsg(&api.SubscribeRequest{Filters: eventFilters}); err != nil {
+		return nil, err
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+func (w *eventWatcher) watch(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := w.subscribe(ctx)
+	if err != nil {
+		return err
+	}
+
+	triggerLog.Debug("Monitoring containerd events")
+
+	for {
+		envelope := &api.Envelope{}
+		if err := stream.RecvMsg(envelope); err != nil {
+			return err
+		}
+
+		logger := triggerLog.WithFields(logrus.Fields{
+			"namespace": envelope.Namespace,
+			"topic":     envelope.Topic,
+		})
+
+		logger.Debug("containerd event")
+
+		if err := w.handle(ctx, envelope); err != nil {
+			logger.WithError(err).Error("Could not handle containerd event")
+		}
+	}
+}
+
+func (w *eventWatcher) handle(ctx context.Context, envelope *api.Envelope) error {
+	if envelope.Event == nil {
+		return nil
+	}
+
+	nsCtx := metadata.NewOutgoingContext(ctx, metadata.Pairs(namespaceHeader, envelope.Namespace))
+
+	switch envelope.Topic {
+	case topicTaskCreate:
+		task := &api.TaskCreate{}
+		if err := proto.Unmarshal(envelope.Event.Value, task); err != nil {
+			return err
+		}
+
+		resp := &api.GetContainerResponse{}
+		if err := grpc.Invoke(nsCtx, containersGetMethod, &api.GetContainerRequest{Id: task.ContainerId}, resp, w.conn); err != nil {
+			return err
+		}
+
+		// Containers join the memory of their sandbox, only
+		// sandboxes get us to kick.
+		container := resp.Container
+		if container == nil || !w.isKata(container.Runtime) || container.Labels[criKindLabel] == criKindContainer {
+			return nil
+		}
+
+		return w.kick(ctx, envelope.Namespace, task.ContainerId)
+
+	case topicSandboxCreate:
+		event := &api.SandboxEvent{}
+		if err := proto.Unmarshal(envelope.Event.Value, event); err != nil {
+			return err
+		}
+
+		resp := &api.StoreGetResponse{}
+		if err := grpc.Invoke(nsCtx, sandboxStoreGetMethod, &api.StoreGetRequest{SandboxId: event.SandboxId}, resp, w.conn); err != nil {
+			return err
+		}
+
+		if resp.Sandbox == nil || !w.isKata(resp.Sandbox.Runtime) {
+			return nil
+		}
+
+		return w.kick(ctx, envelope.Namespace, event.SandboxId)
+
+	case topicTaskDelete:
+		task := &api.TaskDelete{}
+		if err := proto.Unmarshal(envelope.Event.Value, task); err != nil {
+			return err
+		}
+
+		// Exec processes exiting leave their container running
+		if task.Id != "" && task.Id != task.ContainerId {
+			return nil
+		}
+
+		delete(w.sandboxes, sandboxKey(envelope.Namespace, task.ContainerId))
+
+	case topicSandboxExit:
+		event := &api.SandboxEvent{}
+		if err := proto.Unmarshal(envelope.Event.Value, event); err != nil {
+			return err
+		}
+
+		delete(w.sandboxes, sandboxKey(envelope.Namespace, event.SandboxId))
+	}
+
+	return nil
+}
+
+func (w *eventWatcher) isKata(runtime *api.Runtime) bool {
+	return runtime != nil && strings.HasPrefix(runtime.Name, w.runtime)
+}
+
+// sandboxKey identifies a sandbox across containerd namespaces.
+func sandboxKey(namespace, id string) string {
+	return namespace + "/" + id
+}
+
+func (w *eventWatcher) kick(ctx context.Context, namespace, id string) error {
+	key := sandboxKey(namespace, id)
+	if _, ok := w.sandboxes[key]; ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, kickTimeout)
+	defer cancel()
+
+	triggerLog.WithFields(logrus.Fields{
+		"namespace": namespace,
+		"sandbox":   id,
+	}).Debug("Kicking KSM throttler")
+
+	if err := w.throttler.Kick(ctx, &kpb.KickRequest{
+		SandboxId: id,
+		Reason:    "containerd sandbox created",
+	}); err != nil {
+		return err
+	}
+
+	w.sandboxes[key] = struct{}{}
+
+	return nil
+}
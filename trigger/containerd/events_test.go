@@ -0,0 +1,279 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	kpb "github.com/kata-containers/ksm-throttler/pkg/grpc"
+	"github.com/kata-containers/ksm-throttler/trigger/containerd/api"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	testNamespace   = "k8s.io"
+	testKataRuntime = "io.containerd.kata-qemu.v2"
+)
+
+// fakeContainerd stands in for the containerd events, containers and
+// sandbox store services.
+type fakeContainerd struct {
+	sync.Mutex
+
+	containers map[string]*api.Container
+	sandboxes  map[string]*api.Sandbox
+	filters    []string
+	events     chan *api.Envelope
+}
+
+func namespace(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md[namespaceHeader]; len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func (f *fakeContainerd) subscribe(srv interface{}, stream grpc.ServerStream) error {
+	req := &api.SubscribeRequest{}
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+
+	f.Lock()
+	f.filters = req.Filters
+	f.Unlock()
+
+	for {
+		select {
+		case e := <-f.events:
+			if err := stream.SendMsg(e); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (f *fakeContainerd) getContainer(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &api.GetContainerRequest{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	c, ok := f.containers[sandboxKey(namespace(ctx), req.Id)]
+	if !ok {
+		return nil, fmt.Errorf("container %s not found", req.Id)
+	}
+
+	return &api.GetContainerResponse{Container: c}, nil
+}
+
+func (f *fakeContainerd) getSandbox(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &api.StoreGetRequest{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	s, ok := f.sandboxes[sandboxKey(namespace(ctx), req.SandboxId)]
+	if !ok {
+		return nil, fmt.Errorf("sandbox %s not found", req.SandboxId)
+	}
+
+	return &api.StoreGetResponse{Sandbox: s}, nil
+}
+
+func (f *fakeContainerd) register(server *grpc.Server) {
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "containerd.services.events.v1.Events",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "Subscribe",
+			Handler:       f.subscribe,
+			ServerStreams: true,
+		}},
+	}, f)
+
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "containerd.services.containers.v1.Containers",
+		HandlerType: (*interface{})(nil),
+		Methods:     []grpc.MethodDesc{{MethodName: "Get", Handler: f.getContainer}},
+	}, f)
+
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "containerd.services.sandbox.v1.Store",
+		HandlerType: (*interface{})(nil),
+		Methods:     []grpc.MethodDesc{{MethodName: "Get", Handler: f.getSandbox}},
+	}, f)
+}
+
+func (f *fakeContainerd) addContainer(id, runtime, kind string) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.containers[sandboxKey(testNamespace, id)] = &api.Container{
+		Id:      id,
+		Labels:  map[string]string{criKindLabel: kind},
+		Runtime: &api.Runtime{Name: runtime},
+	}
+}
+
+func (f *fakeContainerd) addSandbox(id, runtime string) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.sandboxes[sandboxKey(testNamespace, id)] = &api.Sandbox{
+		SandboxId: id,
+		Runtime:   &api.Runtime{Name: runtime},
+	}
+}
+
+func (f *fakeContainerd) publish(t *testing.T, topic string, event proto.Message) {
+	value, err := proto.Marshal(event)
+	assert.Nil(t, err)
+
+	f.events <- &api.Envelope{
+		Namespace: testNamespace,
+		Topic:     topic,
+		Event:     &any.Any{Value: value},
+	}
+}
+
+// fakeKicker records the kicks it gets.
+type fakeKicker struct {
+	kicks chan *kpb.KickRequest
+}
+
+func (k *fakeKicker) Kick(ctx context.Context, req *kpb.KickRequest) error {
+	k.kicks <- req
+	return nil
+}
+
+func (k *fakeKicker) nextKick(t *testing.T) *kpb.KickRequest {
+	select {
+	case req := <-k.kicks:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No kick received")
+	}
+
+	return nil
+}
+
+func TestEventWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ksmthrottler-containerd")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	address := filepath.Join(dir, "containerd.sock")
+
+	f := &fakeContainerd{
+		containers: make(map[string]*api.Container),
+		sandboxes:  make(map[string]*api.Sandbox),
+		events:     make(chan *api.Envelope),
+	}
+
+	k := &fakeKicker{kicks: make(chan *kpb.KickRequest, 8)}
+
+	w, err := newEventWatcher(address, defaultRuntime, k)
+	assert.Nil(t, err)
+	defer w.close()
+
+	w.retryDelay = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error)
+
+	// The watcher waits for containerd to show up
+	go func() {
+		runErr <- w.run(ctx)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	listener, err := net.Listen("unix", address)
+	assert.Nil(t, err)
+
+	server := grpc.NewServer()
+	f.register(server)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	f.addContainer("sandbox1", testKataRuntime, "sandbox")
+	f.addContainer("container1", testKataRuntime, criKindContainer)
+	f.addContainer("runc1", "io.containerd.runc.v2", "sandbox")
+	f.addSandbox("sandbox2", "io.containerd.kata.v2")
+
+	f.publish(t, topicTaskCreate, &api.TaskCreate{ContainerId: "sandbox1", Pid: 10})
+
+	req := k.nextKick(t)
+	assert.Equal(t, "sandbox1", req.SandboxId)
+	assert.NotEmpty(t, req.Reason)
+
+	f.Lock()
+	assert.Equal(t, eventFilters, f.filters)
+	f.Unlock()
+
+	// Neither containers running in a sandbox, sandboxes we already
+	// kicked for, nor other runtimes sandboxes kick.
+	f.publish(t, topicTaskCreate, &api.TaskCreate{ContainerId: "container1", Pid: 11})
+	f.publish(t, topicTaskCreate, &api.TaskCreate{ContainerId: "runc1", Pid: 12})
+	f.publish(t, topicTaskCreate, &api.TaskCreate{ContainerId: "sandbox1", Pid: 10})
+	f.publish(t, topicTaskCreate, &api.TaskCreate{ContainerId: "unknown", Pid: 13})
+
+	f.publish(t, topicSandboxCreate, &api.SandboxEvent{SandboxId: "sandbox2"})
+
+	req = k.nextKick(t)
+	assert.Equal(t, "sandbox2", req.SandboxId)
+
+	// Exec processes exiting do not delete the sandbox
+	f.publish(t, topicTaskDelete, &api.TaskDelete{ContainerId: "sandbox1", Id: "exec1"})
+	f.publish(t, topicSandboxCreate, &api.SandboxEvent{SandboxId: "sandbox2"})
+	f.publish(t, topicTaskCreate, &api.TaskCreate{ContainerId: "sandbox1", Pid: 10})
+
+	// Sandboxes created again kick
+	f.publish(t, topicTaskDelete, &api.TaskDelete{ContainerId: "sandbox1", Id: "sandbox1"})
+	f.publish(t, topicSandboxExit, &api.SandboxEvent{SandboxId: "sandbox2"})
+	f.publish(t, topicSandboxCreate, &api.SandboxEvent{SandboxId: "sandbox2"})
+	f.publish(t, topicTaskCreate, &api.TaskCreate{ContainerId: "sandbox1", Pid: 14})
+
+	req = k.nextKick(t)
+	assert.Equal(t, "sandbox2", req.SandboxId)
+
+	req = k.nextKick(t)
+	assert.Equal(t, "sandbox1", req.SandboxId)
+	assert.Equal(t, 0, len(k.kicks))
+
+	cancel()
+
+	select {
+	case err = <-runErr:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Event watcher did not stop")
+	}
+}
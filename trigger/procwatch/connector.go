@@ -0,0 +1,185 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// The proc connector constants, from linux/connector.h and
+// linux/cn_proc.h.
+const (
+	cnIdxProc = 1
+	cnValProc = 1
+
+	procCnMcastListen = 1
+	procCnMcastIgnore = 2
+
+	procEventExec = 0x00000002
+	procEventExit = 0x80000000
+
+	// cnMsgLen is the size of struct cn_msg.
+	cnMsgLen = 20
+
+	// procEventHeaderLen is the size of the struct proc_event header:
+	// what, cpu and timestamp_ns.
+	procEventHeaderLen = 16
+)
+
+type procEventKind int
+
+const (
+	procExec procEventKind = iota
+	procExit
+)
+
+// procEvent is an exec or an exit, as reported by the proc connector.
+type procEvent struct {
+	kind procEventKind
+	pid  int
+	tgid int
+}
+
+// nativeEndian is the byte order of the connector messages.
+var nativeEndian binary.ByteOrder
+
+func init() {
+	i := uint16(1)
+	if *(*byte)(unsafe.Pointer(&i)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// procConnector receives the process events from the netlink proc
+// connector. It requires the CAP_NET_ADMIN capability.
+type procConnector struct {
+	fd int
+}
+
+func newProcConnector() (*procConnector, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_CONNECTOR)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &procConnector{fd: fd}
+
+	addr := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: cnIdxProc,
+		Pid:    uint32(os.Getpid()),
+	}
+
+	if err := syscall.Bind(fd, addr); err != nil {
+		c.close()
+		return nil, err
+	}
+
+	if err := c.listen(procCnMcastListen); err != nil {
+		c.close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// listen asks the kernel to start or stop sending process events.
+func (c *procConnector) listen(op uint32) error {
+	buf := make([]byte, syscall.NLMSG_HDRLEN+cnMsgLen+4)
+
+	// struct nlmsghdr
+	nativeEndian.PutUint32(buf[0:], uint32(len(buf)))
+	nativeEndian.PutUint16(buf[4:], syscall.NLMSG_DONE)
+	nativeEndian.PutUint32(buf[12:], uint32(os.Getpid()))
+
+	// struct cn_msg, followed by the operation
+	msg := buf[syscall.NLMSG_HDRLEN:]
+	nativeEndian.PutUint32(msg[0:], cnIdxProc)
+	nativeEndian.PutUint32(msg[4:], cnValProc)
+	nativeEndian.PutUint16(msg[16:], 4)
+	nativeEndian.PutUint32(msg[cnMsgLen:], op)
+
+	return syscall.Sendto(c.fd, buf, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK})
+}
+
+// receive waits for the next process events. It fails with ENOBUFS when
+// we did not keep up with the kernel, and events got lost.
+func (c *procConnector) receive() ([]procEvent, error) {
+	buf := make([]byte, os.Getpagesize())
+
+	n, _, err := syscall.Recvfrom(c.fd, buf, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseProcEvents(buf[:n])
+}
+
+func (c *procConnector) close() error {
+	c.listen(procCnMcastIgnore)
+	return syscall.Close(c.fd)
+}
+
+// parseProcEvents decodes the exec and exit events of a netlink
+// datagram. The other events are skipped.
+func parseProcEvents(buf []byte) ([]procEvent, error) {
+	msgs, err := syscall.ParseNetlinkMessage(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []procEvent
+
+	for _, msg := range msgs {
+		if msg.Header.Type != syscall.NLMSG_DONE {
+			continue
+		}
+
+		data := msg.Data
+		if len(data) < cnMsgLen+procEventHeaderLen {
+			return nil, fmt.Errorf("Short proc connector message (%d bytes)", len(data))
+		}
+
+		if nativeEndian.Uint32(data[0:]) != cnIdxProc || nativeEndian.Uint32(data[4:]) != cnValProc {
+			continue
+		}
+
+		event := data[cnMsgLen:]
+		what := nativeEndian.Uint32(event[0:])
+		body := event[procEventHeaderLen:]
+
+		var kind procEventKind
+		switch what {
+		case procEventExec:
+			kind = procExec
+		case procEventExit:
+			kind = procExit
+		default:
+			continue
+		}
+
+		// Both exec_proc_event and exit_proc_event start with
+		// process_pid and process_tgid.
+		if len(body) < 8 {
+			return nil, fmt.Errorf("Short proc connector event (%d bytes)", len(body))
+		}
+
+		events = append(events, procEvent{
+			kind: kind,
+			pid:  int(int32(nativeEndian.Uint32(body[0:]))),
+			tgid: int(int32(nativeEndian.Uint32(body[4:]))),
+		})
+	}
+
+	return events, nil
+}
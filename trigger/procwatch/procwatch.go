@@ -0,0 +1,167 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kata-containers/ksm-throttler/pkg/client"
+	ksig "github.com/kata-containers/ksm-throttler/pkg/signals"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// DefaultURI is populated at link time - see the Makefile
+var DefaultURI string
+
+var triggerLog = logrus.WithFields(logrus.Fields{
+	"source": "throttler-trigger",
+	"name":   "procwatch",
+	"pid":    os.Getpid(),
+})
+
+const (
+	defaultgRPCSocket = "/var/run/kata-ksm-throttler/ksm.sock"
+	defaultPatterns   = "qemu-system-*,qemu-kvm,cloud-hypervisor,firecracker"
+	defaultSettle     = time.Second
+)
+
+func setLoggingLevel(l string) error {
+	level, err := logrus.ParseLevel(l)
+	if err != nil {
+		return err
+	}
+
+	triggerLog.Logger.SetLevel(level)
+
+	triggerLog.Logger.Formatter = &logrus.TextFormatter{TimestampFormat: time.RFC3339Nano}
+
+	return nil
+}
+
+func setupSignalHandler(cancel context.CancelFunc) {
+	sigCh := make(chan os.Signal, 8)
+
+	for _, sig := range ksig.HandledSignals() {
+		signal.Notify(sigCh, sig)
+	}
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		for {
+			sig := <-sigCh
+
+			nativeSignal, ok := sig.(syscall.Signal)
+			if !ok {
+				err := errors.New("unknown signal")
+				triggerLog.WithError(err).WithField("signal", sig.String()).Error()
+				continue
+			}
+
+			if nativeSignal == syscall.SIGINT || nativeSignal == syscall.SIGTERM {
+				triggerLog.WithField("signal", sig).Debug("stopping")
+				cancel()
+			} else if ksig.FatalSignal(nativeSignal) {
+				triggerLog.WithField("signal", sig).Error("received fatal signal")
+				ksig.Die()
+			} else if ksig.NonFatalSignal(nativeSignal) {
+				triggerLog.WithField("signal", sig).Debug("handling signal")
+				ksig.Backtrace()
+			}
+		}
+	}()
+}
+
+// receiveEvents forwards the proc connector events until it fails.
+func receiveEvents(c *procConnector, events chan<- procEvent) {
+	defer close(events)
+
+	for {
+		received, err := c.receive()
+		if err == syscall.ENOBUFS {
+			triggerLog.Warn("Process events lost")
+			continue
+		} else if err != nil {
+			triggerLog.WithError(err).Error("Could not receive process events")
+			return
+		}
+
+		for _, e := range received {
+			events <- e
+		}
+	}
+}
+
+func main() {
+	uri := flag.String("uri", "", "KSM throttler gRPC URI")
+	match := flag.String("match", defaultPatterns, "comma separated VM executable name patterns")
+	settle := flag.Duration("settle", defaultSettle, "how long a VM process must run before kicking the throttler")
+	logLevel := flag.String("log", "warn",
+		"log messages above specified level; one of debug, warn, error, fatal or panic")
+	flag.Parse()
+
+	if err := setLoggingLevel(*logLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not set logging level %s: %v", *logLevel, err)
+		os.Exit(1)
+	}
+
+	ksig.SetLogger(triggerLog)
+
+	patterns, err := parsePatterns(*match)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Invoking "go build" without any linker option will not
+	// populate DefaultURI, so fallback to a reasonable path.
+	if DefaultURI == "" {
+		DefaultURI = defaultgRPCSocket
+	}
+
+	if *uri == "" {
+		*uri = DefaultURI
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	setupSignalHandler(cancel)
+
+	throttler, err := client.New(*uri, client.DefaultOptions())
+	if err != nil {
+		triggerLog.WithError(err).WithField("throttler", *uri).Error("Could not create KSM throttler client")
+		os.Exit(1)
+	}
+	defer throttler.Close()
+
+	connector, err := newProcConnector()
+	if err != nil {
+		triggerLog.WithError(err).Error("Could not listen to the proc connector")
+		os.Exit(1)
+	}
+	defer connector.close()
+
+	events := make(chan procEvent, 64)
+	go receiveEvents(connector, events)
+
+	watcher := newVMWatcher(patterns, *settle, throttler)
+
+	// Listen first, not to miss the VMs starting while we scan
+	if err := watcher.scan(ctx); err != nil {
+		triggerLog.WithError(err).Error("Could not scan the running processes")
+	}
+
+	if err := watcher.run(ctx, events); err != nil {
+		triggerLog.WithError(err).Error("Could not monitor processes")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,257 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	kpb "github.com/kata-containers/ksm-throttler/pkg/grpc"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// procRoot is where we read the processes executables from.
+var procRoot = "/proc"
+
+// callTimeout bounds each call to the throttler.
+const callTimeout = 10 * time.Second
+
+var errEventsClosed = errors.New("Process events stream closed")
+
+// throttler is the part of the KSM throttler client we use.
+type throttler interface {
+	Kick(ctx context.Context, req *kpb.KickRequest) error
+	Release(ctx context.Context, sandboxID string, pids []int32) error
+}
+
+// parsePatterns parses a comma separated list of executable name
+// patterns, e.g. "qemu-system-*,firecracker".
+func parsePatterns(s string) ([]string, error) {
+	var patterns []string
+
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("Invalid executable pattern %q: %v", p, err)
+		}
+
+		patterns = append(patterns, p)
+	}
+
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("No executable pattern")
+	}
+
+	return patterns, nil
+}
+
+// vmWatcher kicks the KSM throttler when a VM starts, and releases the
+// VM when it exits. VMs are processes whose executable name matches one
+// of our patterns, and that run for the settle delay: Processes
+// exec'ing several times in a row kick once, and short lived ones, e.g.
+// capabilities probes, do not kick.
+type vmWatcher struct {
+	patterns  []string
+	settle    time.Duration
+	throttler throttler
+
+	// pending maps the processes waiting to settle to their deadline.
+	pending map[int]time.Time
+
+	// vms maps the running VMs to their sandbox ID.
+	vms map[int]string
+
+	settled chan int
+}
+
+func newVMWatcher(patterns []string, settle time.Duration, t throttler) *vmWatcher {
+	return &vmWatcher{
+		patterns:  patterns,
+		settle:    settle,
+		throttler: t,
+		pending:   make(map[int]time.Time),
+		vms:       make(map[int]string),
+		settled:   make(chan int),
+	}
+}
+
+// executable returns the executable name of a process, and whether it
+// matches our patterns.
+func (w *vmWatcher) executable(pid int) (string, bool) {
+	var name string
+
+	dir := filepath.Join(procRoot, strconv.Itoa(pid))
+
+	if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil {
+		name = filepath.Base(strings.TrimSuffix(exe, " (deleted)"))
+	} else if comm, err := ioutil.ReadFile(filepath.Join(dir, "comm")); err == nil {
+		// The kernel truncates comm, but it is readable without
+		// ptrace access.
+		name = strings.TrimSpace(string(comm))
+	} else {
+		return "", false
+	}
+
+	for _, p := range w.patterns {
+		if match, _ := filepath.Match(p, name); match {
+			return name, true
+		}
+	}
+
+	return name, false
+}
+
+// scan registers the VMs already running.
+func (w *vmWatcher) scan(ctx context.Context) error {
+	entries, err := ioutil.ReadDir(procRoot)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+
+		if name, match := w.executable(pid); match {
+			w.start(ctx, pid, name)
+		}
+	}
+
+	return nil
+}
+
+// run handles the process events until ctx is cancelled. It fails once
+// events is closed.
+func (w *vmWatcher) run(ctx context.Context, events <-chan procEvent) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case e, ok := <-events:
+			if !ok {
+				return errEventsClosed
+			}
+
+			w.handle(ctx, e)
+
+		case pid := <-w.settled:
+			deadline, ok := w.pending[pid]
+			if !ok || time.Now().Before(deadline) {
+				// The process exited, or its PID got reused
+				// and has a later deadline.
+				continue
+			}
+
+			delete(w.pending, pid)
+
+			// The process may have exec'ed something else
+			if name, match := w.executable(pid); match {
+				w.start(ctx, pid, name)
+			}
+		}
+	}
+}
+
+func (w *vmWatcher) handle(ctx context.Context, e procEvent) {
+	// Threads come and go with their VM
+	if e.pid != e.tgid {
+		return
+	}
+
+	pid := e.pid
+
+	switch e.kind {
+	case procExec:
+		if id, ok := w.vms[pid]; ok {
+			// The VM exec'ed something else: It is gone
+			if _, match := w.executable(pid); !match {
+				delete(w.vms, pid)
+				w.release(ctx, pid, id)
+			}
+			return
+		}
+
+		if _, ok := w.pending[pid]; ok {
+			return
+		}
+
+		if _, match := w.executable(pid); !match {
+			return
+		}
+
+		w.pending[pid] = time.Now().Add(w.settle)
+
+		time.AfterFunc(w.settle, func() {
+			select {
+			case w.settled <- pid:
+			case <-ctx.Done():
+			}
+		})
+
+	case procExit:
+		delete(w.pending, pid)
+
+		if id, ok := w.vms[pid]; ok {
+			delete(w.vms, pid)
+			w.release(ctx, pid, id)
+		}
+	}
+}
+
+func (w *vmWatcher) start(ctx context.Context, pid int, name string) {
+	id := fmt.Sprintf("%s-%d", name, pid)
+
+	logger := triggerLog.WithFields(logrus.Fields{
+		"pid":     pid,
+		"sandbox": id,
+	})
+
+	logger.Debug("VM started, kicking KSM throttler")
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	if err := w.throttler.Kick(ctx, &kpb.KickRequest{
+		SandboxId: id,
+		Pids:      []int32{int32(pid)},
+		Reason:    name + " started",
+	}); err != nil {
+		logger.WithError(err).Error("Could not kick the throttler")
+		return
+	}
+
+	w.vms[pid] = id
+}
+
+func (w *vmWatcher) release(ctx context.Context, pid int, id string) {
+	logger := triggerLog.WithFields(logrus.Fields{
+		"pid":     pid,
+		"sandbox": id,
+	})
+
+	logger.Debug("VM exited, releasing it")
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	if err := w.throttler.Release(ctx, id, nil); err != nil {
+		logger.WithError(err).Error("Could not release the VM")
+	}
+}
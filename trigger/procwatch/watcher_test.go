@@ -0,0 +1,215 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	kpb "github.com/kata-containers/ksm-throttler/pkg/grpc"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+// fakeThrottler records the kicks and releases it gets.
+type fakeThrottler struct {
+	kicks    chan *kpb.KickRequest
+	releases chan string
+}
+
+func (f *fakeThrottler) Kick(ctx context.Context, req *kpb.KickRequest) error {
+	f.kicks <- req
+	return nil
+}
+
+func (f *fakeThrottler) Release(ctx context.Context, sandboxID string, pids []int32) error {
+	f.releases <- sandboxID
+	return nil
+}
+
+func (f *fakeThrottler) nextKick(t *testing.T) *kpb.KickRequest {
+	select {
+	case req := <-f.kicks:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No kick received")
+	}
+
+	return nil
+}
+
+func (f *fakeThrottler) nextRelease(t *testing.T) string {
+	select {
+	case id := <-f.releases:
+		return id
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No release received")
+	}
+
+	return ""
+}
+
+func setTestProc(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "ksmthrottler-procwatch")
+	assert.Nil(t, err)
+
+	savedProcRoot := procRoot
+	procRoot = dir
+
+	return func() {
+		procRoot = savedProcRoot
+		os.RemoveAll(dir)
+	}
+}
+
+func addTestProcess(t *testing.T, pid int, exe string) {
+	dir := filepath.Join(procRoot, fmt.Sprintf("%d", pid))
+
+	err := os.MkdirAll(dir, 0755)
+	assert.Nil(t, err)
+
+	err = os.Symlink(exe, filepath.Join(dir, "exe"))
+	assert.Nil(t, err)
+}
+
+func TestParsePatterns(t *testing.T) {
+	patterns, err := parsePatterns(defaultPatterns)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"qemu-system-*", "qemu-kvm", "cloud-hypervisor", "firecracker"}, patterns)
+
+	patterns, err = parsePatterns(" firecracker, ")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"firecracker"}, patterns)
+
+	_, err = parsePatterns("qemu-[")
+	assert.NotNil(t, err)
+
+	_, err = parsePatterns(",")
+	assert.NotNil(t, err)
+}
+
+func TestVMWatcher(t *testing.T) {
+	cleanup := setTestProc(t)
+	defer cleanup()
+
+	f := &fakeThrottler{
+		kicks:    make(chan *kpb.KickRequest, 8),
+		releases: make(chan string, 8),
+	}
+
+	patterns, err := parsePatterns(defaultPatterns)
+	assert.Nil(t, err)
+
+	w := newVMWatcher(patterns, 50*time.Millisecond, f)
+
+	// VMs already running are registered first
+	addTestProcess(t, 10, "/usr/bin/cloud-hypervisor")
+	addTestProcess(t, 11, "/usr/bin/bash")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = w.scan(ctx)
+	assert.Nil(t, err)
+
+	req := f.nextKick(t)
+	assert.Equal(t, "cloud-hypervisor-10", req.SandboxId)
+	assert.Equal(t, []int32{10}, req.Pids)
+
+	events := make(chan procEvent)
+	runErr := make(chan error)
+
+	go func() {
+		runErr <- w.run(ctx, events)
+	}()
+
+	addTestProcess(t, 100, "/usr/bin/qemu-system-x86_64")
+	addTestProcess(t, 101, "/usr/bin/firecracker")
+	addTestProcess(t, 102, "/usr/bin/sh")
+
+	// Bursts of execs kick once, short lived VMs and other
+	// executables do not kick.
+	events <- procEvent{kind: procExec, pid: 100, tgid: 100}
+	events <- procEvent{kind: procExec, pid: 100, tgid: 100}
+	events <- procEvent{kind: procExec, pid: 101, tgid: 101}
+	events <- procEvent{kind: procExec, pid: 102, tgid: 102}
+	events <- procEvent{kind: procExit, pid: 101, tgid: 101}
+
+	req = f.nextKick(t)
+	assert.Equal(t, "qemu-system-x86_64-100", req.SandboxId)
+	assert.Equal(t, []int32{100}, req.Pids)
+	assert.Equal(t, "qemu-system-x86_64 started", req.Reason)
+
+	// Running VMs exec'ing again do not kick either
+	events <- procEvent{kind: procExec, pid: 100, tgid: 100}
+
+	// Threads exiting do not release their VM
+	events <- procEvent{kind: procExit, pid: 103, tgid: 100}
+	events <- procEvent{kind: procExit, pid: 100, tgid: 100}
+
+	assert.Equal(t, "qemu-system-x86_64-100", f.nextRelease(t))
+
+	// VMs exec'ing another executable are released once
+	err = os.Remove(filepath.Join(procRoot, "10", "exe"))
+	assert.Nil(t, err)
+	addTestProcess(t, 10, "/usr/bin/bash")
+
+	events <- procEvent{kind: procExec, pid: 10, tgid: 10}
+	assert.Equal(t, "cloud-hypervisor-10", f.nextRelease(t))
+
+	events <- procEvent{kind: procExit, pid: 10, tgid: 10}
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, len(f.kicks))
+	assert.Equal(t, 0, len(f.releases))
+
+	close(events)
+	assert.Equal(t, errEventsClosed, <-runErr)
+}
+
+// procConnectorMessage builds a proc connector netlink message.
+func procConnectorMessage(what uint32, pid, tgid int) []byte {
+	buf := make([]byte, syscall.NLMSG_HDRLEN+cnMsgLen+procEventHeaderLen+24)
+
+	nativeEndian.PutUint32(buf[0:], uint32(len(buf)))
+	nativeEndian.PutUint16(buf[4:], syscall.NLMSG_DONE)
+
+	msg := buf[syscall.NLMSG_HDRLEN:]
+	nativeEndian.PutUint32(msg[0:], cnIdxProc)
+	nativeEndian.PutUint32(msg[4:], cnValProc)
+	nativeEndian.PutUint16(msg[16:], uint16(procEventHeaderLen+24))
+
+	event := msg[cnMsgLen:]
+	nativeEndian.PutUint32(event[0:], what)
+	nativeEndian.PutUint32(event[procEventHeaderLen:], uint32(pid))
+	nativeEndian.PutUint32(event[procEventHeaderLen+4:], uint32(tgid))
+
+	return buf
+}
+
+func TestParseProcEvents(t *testing.T) {
+	// PROC_EVENT_FORK events are skipped
+	var buf []byte
+	buf = append(buf, procConnectorMessage(procEventExec, 10, 10)...)
+	buf = append(buf, procConnectorMessage(0x00000001, 11, 11)...)
+	buf = append(buf, procConnectorMessage(procEventExit, 12, 10)...)
+
+	events, err := parseProcEvents(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, []procEvent{
+		{kind: procExec, pid: 10, tgid: 10},
+		{kind: procExit, pid: 12, tgid: 10},
+	}, events)
+
+	_, err = parseProcEvents(buf[:syscall.NLMSG_HDRLEN+cnMsgLen])
+	assert.NotNil(t, err)
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/kata-containers/ksm-throttler/pkg/client"
 	ksig "github.com/kata-containers/ksm-throttler/pkg/signals"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
 )
 
 // DefaultURI is populated at link time with the value of:
@@ -42,8 +43,19 @@ const (
 	// In linux the max socket path is 108 including null character
 	// see http://man7.org/linux/man-pages/man7/unix.7.html
 	socketPathMaxLength = 107
+
+	// kickTimeout bounds each kick, retries included.
+	kickTimeout = 10 * time.Second
 )
 
+// kick kicks the throttler, giving up after kickTimeout.
+func kick(throttler *client.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kickTimeout)
+	defer cancel()
+
+	return throttler.Kick(ctx, nil)
+}
+
 // getSocketPath computes the path of the KSM throttler socket.
 // Note that when socket activated, the socket path is specified
 // in the systemd socket file but the same value is set in
@@ -122,7 +134,7 @@ func waitForDirectory(dir string) error {
 	return err
 }
 
-func monitorPods(vcRunRoot, throttler string) error {
+func monitorPods(vcRunRoot string, throttler *client.Client) error {
 	var wg sync.WaitGroup
 
 	watcher, err := fsnotify.NewWatcher()
@@ -132,10 +144,7 @@ func monitorPods(vcRunRoot, throttler string) error {
 	}
 	defer watcher.Close()
 
-	logger := triggerLog.WithFields(logrus.Fields{
-		"vc-root":   vcRunRoot,
-		"throttler": throttler,
-	})
+	logger := triggerLog.WithField("vc-root", vcRunRoot)
 
 	// Wait for vc root if it does not exist
 	if _, err := os.Stat(vcRunRoot); os.IsNotExist(err) {
@@ -157,7 +166,7 @@ func monitorPods(vcRunRoot, throttler string) error {
 		}
 
 		// First pod created, we should kick the throttler
-		if err := client.Kick(throttler); err != nil {
+		if err := kick(throttler); err != nil {
 			logger.WithError(err).Error("Could not kick the throttler")
 			return err
 		}
@@ -177,7 +186,7 @@ func monitorPods(vcRunRoot, throttler string) error {
 				}
 
 				logger.Debug("Kicking KSM throttler")
-				if err := client.Kick(throttler); err != nil {
+				if err := kick(throttler); err != nil {
 					logger.WithError(err).Error("Could not kick the throttler")
 					continue
 				}
@@ -269,7 +278,14 @@ func main() {
 
 	setupSignalHandler()
 
-	if err := monitorPods(*vcRoot, uri); err != nil {
+	throttler, err := client.New(uri, client.DefaultOptions())
+	if err != nil {
+		logrus.WithError(err).WithField("throttler", uri).Error("Could not create KSM throttler client")
+		os.Exit(1)
+	}
+	defer throttler.Close()
+
+	if err := monitorPods(*vcRoot, throttler); err != nil {
 		logrus.WithError(err).Error("Could not monitor pods")
 		os.Exit(1)
 	}
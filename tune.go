@@ -0,0 +1,124 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+)
+
+// sysfsChange is a KSM sysfs attribute a transaction changed, and the
+// value to roll it back to.
+type sysfsChange struct {
+	name     string
+	previous string
+}
+
+// sysfsTransaction changes KSM sysfs attributes, skipping the writes
+// that would not change anything, and rolls the changed attributes back
+// when a later write fails. ksmd keeps running while pages_to_scan and
+// sleep_millisecs change: Stopping it would restart its scan.
+type sysfsTransaction struct {
+	k       *ksm
+	changes []sysfsChange
+
+	// unmerge allows unmerging all pages to change busy knobs.
+	unmerge bool
+}
+
+// begin is unlocked. You should take the ksm lock before calling it.
+// It starts a transaction, saving the run value for the rollback: Knobs
+// writes may have to unmerge all pages, which changes it.
+func (k *ksm) begin(unmerge bool) (*sysfsTransaction, error) {
+	run, err := k.run.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return &sysfsTransaction{
+		k:       k,
+		changes: []sysfsChange{{name: ksmRunFile, previous: knobValue(run)}},
+		unmerge: unmerge,
+	}, nil
+}
+
+// attribute returns a KSM sysfs attribute and how to write it, or false
+// when the running kernel does not export it.
+func (k *ksm) attribute(name string, unmerge bool) (*sysfsAttribute, func(string) error, bool) {
+	switch name {
+	case ksmRunFile:
+		return &k.run, k.run.write, true
+	case ksmPagesToScan:
+		return &k.pagesToScan, k.pagesToScan.write, true
+	case ksmSleepMillisec:
+		return &k.sleepInterval, k.sleepInterval.write, true
+	}
+
+	knob, ok := k.knobs[name]
+	if !ok {
+		return nil, nil, false
+	}
+
+	return &knob.attr, func(value string) error { return k.writeKnob(name, value, unmerge) }, true
+}
+
+// set writes value to a KSM attribute, unless it already holds it.
+func (t *sysfsTransaction) set(name, value string) error {
+	attr, write, ok := t.k.attribute(name, t.unmerge)
+	if !ok {
+		throttlerLog.WithField("knob", name).Debug("KSM knob not available, skipping")
+		return nil
+	}
+
+	current, err := attr.read()
+	if err != nil {
+		return fmt.Errorf("Could not read KSM %s: %v", name, err)
+	}
+
+	current = knobValue(current)
+	if current == value {
+		return nil
+	}
+
+	if err := write(value); err != nil {
+		return err
+	}
+
+	t.changes = append(t.changes, sysfsChange{name: name, previous: current})
+
+	return nil
+}
+
+// setKnobs writes the knobs values, in the ksmKnobs order.
+func (t *sysfsTransaction) setKnobs(values map[string]string) error {
+	for _, name := range ksmKnobs {
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+
+		if err := t.set(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollback restores the attributes the transaction changed, from the
+// last changed one. It keeps going when a write fails, to restore as
+// much as possible.
+func (t *sysfsTransaction) rollback() {
+	for i := len(t.changes) - 1; i >= 0; i-- {
+		c := t.changes[i]
+
+		if err := t.set(c.name, c.previous); err != nil {
+			throttlerLog.WithError(err).WithField("attribute", c.name).Error("Could not roll back KSM attribute")
+		}
+	}
+
+	t.changes = nil
+}
@@ -0,0 +1,97 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readOnly reopens a sysfs attribute read only, for writes to fail.
+func readOnly(t *testing.T, attr *sysfsAttribute) func() {
+	err := attr.close()
+	assert.Nil(t, err)
+
+	attr.file, err = os.Open(attr.path)
+	assert.Nil(t, err)
+
+	return func() {
+		_ = attr.close()
+		err := attr.open()
+		assert.Nil(t, err)
+	}
+}
+
+func readAttribute(t *testing.T, attr *sysfsAttribute) string {
+	value, err := attr.read()
+	assert.Nil(t, err)
+
+	return value
+}
+
+func TestKSMTuneTransaction(t *testing.T) {
+	k := initKSM(defaultKSMRoot, t)
+
+	err := k.tune(ksmSettings[ksmStandard])
+	assert.Nil(t, err)
+
+	standardPages := readAttribute(t, &k.pagesToScan)
+
+	// ksmd keeps running while we change its settings
+	restoreRun := readOnly(t, &k.run)
+
+	err = k.tune(ksmSettings[ksmAggressive])
+	assert.Nil(t, err)
+	assert.Equal(t, ksmStart, readAttribute(t, &k.run))
+	assert.Equal(t, fmt.Sprintf("%d", ksmSettings[ksmAggressive].scanIntervalMS), readAttribute(t, &k.sleepInterval))
+
+	aggressivePages := readAttribute(t, &k.pagesToScan)
+	assert.NotEqual(t, standardPages, aggressivePages)
+
+	restoreRun()
+
+	// Failing writes roll the previous ones back
+	restoreSleep := readOnly(t, &k.sleepInterval)
+	defer restoreSleep()
+
+	err = k.tune(ksmSettings[ksmStandard])
+	assert.NotNil(t, err)
+	assert.Equal(t, aggressivePages, readAttribute(t, &k.pagesToScan))
+	assert.Equal(t, ksmStart, readAttribute(t, &k.run))
+
+	// Unchanged values are not written
+	err = k.tune(ksmSettings[ksmAggressive])
+	assert.Nil(t, err)
+}
+
+func TestKSMTuneStopRollback(t *testing.T) {
+	knobs := map[string]string{ksmUseZeroPages: "0"}
+
+	err := writeKSMCounters(knobs)
+	defer removeKSMCounters(knobs)
+	assert.Nil(t, err)
+
+	k := initKSM(defaultKSMRoot, t)
+	defer k.closeKnobs()
+
+	err = k.tune(ksmSettings[ksmStandard])
+	assert.Nil(t, err)
+
+	restoreKnob := readOnly(t, &k.knobs[ksmUseZeroPages].attr)
+	defer restoreKnob()
+
+	setting := ksmSettings[ksmOff]
+	setting.knobs = map[string]string{ksmUseZeroPages: "1"}
+
+	// We do not leave ksmd stopped when the knobs can not be written
+	err = k.tune(setting)
+	assert.NotNil(t, err)
+	assert.Equal(t, ksmStart, readAttribute(t, &k.run))
+}